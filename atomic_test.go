@@ -0,0 +1,126 @@
+package radix_test
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	. "github.com/knnat/radix"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAtomic(t *testing.T) {
+	labels := []string{
+		"romane", "romanus", "romulus", "rubens", "ruber", "rubicon", "rubicundus",
+		"r", "rom", "/users/@id", "/users/all",
+	}
+	plain := (&Settings{Flags: Tnocolor | Tdebug, Escape: '@', Delimiter: '/'}).New()
+	tr := (&Settings{Flags: Tatomic | Tnocolor | Tdebug, Escape: '@', Delimiter: '/'}).New()
+	for i, l := range labels {
+		assert.Nil(t, plain.Add(l, i))
+		assert.Nil(t, tr.Add(l, i))
+		assert.Equal(t, plain.String(), tr.String())
+	}
+	assert.Equal(t, plain.Len(), tr.Len())
+	assert.Equal(t, plain.Size(), tr.Size())
+
+	// Nodes that were read are never changed by writers.
+	n, _ := tr.Get("romanus")
+	depth := n.Depth()
+	assert.Nil(t, tr.Add("roma", 100))
+	assert.Nil(t, tr.Set("romanus", 200))
+	assert.Equal(t, depth, n.Depth())
+	assert.Equal(t, 1, n.Value)
+	n, _ = tr.Get("romanus")
+	assert.Equal(t, depth+1, n.Depth())
+	assert.Equal(t, 200, n.Value)
+
+	assert.Nil(t, plain.Add("roma", 100))
+	assert.Nil(t, plain.Set("romanus", 200))
+	for _, l := range labels {
+		plain.Del(l)
+		tr.Del(l)
+		assert.Equal(t, plain.String(), tr.String())
+	}
+	plain.Sort(AscLabelSort)
+	tr.Sort(AscLabelSort)
+	assert.Equal(t, plain.String(), tr.String())
+	assert.Equal(t, plain.Len(), tr.Len())
+	assert.Equal(t, plain.Size(), tr.Size())
+
+	err := tr.Batch(func(b *Batch) error {
+		b.Add("foo", 1)
+		n, _ := tr.Get("foo")
+		assert.Nil(t, n) // not published yet
+		n, _ = b.Get("foo")
+		assert.Equal(t, 1, n.Value)
+		return nil
+	})
+	assert.Nil(t, err)
+	n, _ = tr.Get("foo")
+	assert.Equal(t, 1, n.Value)
+}
+
+func TestAtomicDepth(t *testing.T) {
+	plain := (&Settings{Escape: '@', Delimiter: '/'}).New()
+	tr := (&Settings{Flags: Tatomic, Escape: '@', Delimiter: '/'}).New()
+	ops := []string{"abcdef1", "abcdef2", "abcdef1x", "abc", "ab", "abcd", "-abc", "-ab", "a", "abcdef1xy", "-abcd", "-a"}
+	for _, op := range ops {
+		for _, tr := range []*Tree{plain, tr} {
+			if op[0] == '-' {
+				tr.Del(op[1:])
+			} else {
+				assert.Nil(t, tr.Add(op, op))
+			}
+		}
+		depths := func(tr *Tree) map[string]int {
+			m := make(map[string]int)
+			tr.Walk(func(label string, n *Node) bool {
+				m[label] = n.Depth()
+				g, _ := tr.Get(label)
+				assert.Equal(t, n.Depth(), g.Depth(), label)
+				return true
+			})
+			return m
+		}
+		assert.Equal(t, depths(plain), depths(tr), "after %s", op)
+	}
+}
+
+func TestAtomicRace(t *testing.T) {
+	tr := (&Settings{Flags: Tatomic, Escape: '@', Delimiter: '/'}).New()
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 200; j++ {
+				label := fmt.Sprintf("/%d/%d", j, i)
+				tr.Add(label, j)
+				if j%3 == 0 {
+					tr.Del(label)
+				}
+			}
+		}(i)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 200; j++ {
+				if n, _ := tr.Get(fmt.Sprintf("/%d/0", j)); n != nil {
+					_ = n.Value
+					_ = n.Depth()
+				}
+				tr.Walk(func(string, *Node) bool { return true })
+				_ = tr.Len()
+				_ = tr.String()
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, 4*(200-67), func() (count int) {
+		tr.Walk(func(string, *Node) bool {
+			count++
+			return true
+		})
+		return count
+	}())
+}
@@ -0,0 +1,135 @@
+package radix
+
+// Batch runs several operations on a tree under a single lock acquisition.
+//
+// Every operation is applied to the tree right away, so reads
+// made through the batch see the batch's own changes.
+type Batch struct {
+	trees []*Tree                  // the trees the batch locked
+	tree  func(label string) *Tree // the tree holding a label
+	get   func(label string) (*Node, map[string]string)
+}
+
+// snapshot is the state of a tree before a batch. While the batch runs,
+// writers copy the nodes they modify, so that the snapshot's nodes are
+// left as they were, except for the depths of the subtrees that writers
+// move up or down, which are recorded to be shifted back.
+type snapshot struct {
+	root   *Node
+	length int
+	size   int
+	shifts []shift
+}
+
+// shift is a change of depth of the nodes below some edges.
+type shift struct {
+	edges []*edge
+	by    int
+}
+
+// Batch runs fn with a batch for the tree.
+//
+// If fn returns an error or panics, all operations made
+// through the batch are reverted, leaving the tree unchanged,
+// and the error is returned. To that end, nodes in the paths
+// of the batch's labels are copied rather than modified.
+//
+// Lock-free readers only see the batch's changes after fn returns.
+func (tr *Tree) Batch(fn func(b *Batch) error) error {
+	if tr.safe {
+		defer tr.mu.Unlock()
+		tr.mu.Lock()
+	}
+	if tr.atomic {
+		defer tr.publish()
+	}
+	b := &Batch{
+		trees: []*Tree{tr},
+		tree:  func(string) *Tree { return tr },
+		get: func(label string) (*Node, map[string]string) {
+			return tr.get(tr.root, label)
+		},
+	}
+	return b.run(fn)
+}
+
+// run runs fn with the batch, reverting all of its operations on failure.
+func (b *Batch) run(fn func(b *Batch) error) (err error) {
+	for _, tr := range b.trees {
+		tr.snap = &snapshot{root: tr.root, length: tr.length, size: tr.size}
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			b.rollback()
+			panic(r)
+		}
+		b.commit()
+	}()
+	if err = fn(b); err != nil {
+		b.rollback()
+	}
+	return err
+}
+
+// commit keeps the trees as the batch left them.
+func (b *Batch) commit() {
+	for _, tr := range b.trees {
+		tr.snap = nil
+	}
+}
+
+// Add adds a new node to the tree.
+func (b *Batch) Add(label string, v interface{}) error {
+	// No empty strings or interfaces allowed.
+	if label == "" || v == nil {
+		return nil
+	}
+	_, err := b.tree(label).add(label, v, false)
+	return err
+}
+
+// Set adds a new node to the tree or, if the label
+// already exists, replaces its node's value.
+func (b *Batch) Set(label string, v interface{}) error {
+	// No empty strings or interfaces allowed.
+	if label == "" || v == nil {
+		return nil
+	}
+	_, err := b.tree(label).add(label, v, true)
+	return err
+}
+
+// Del deletes a node.
+func (b *Batch) Del(label string) {
+	if label == "" {
+		return
+	}
+	b.tree(label).del(label)
+}
+
+// Get retrieves a node.
+func (b *Batch) Get(label string) (*Node, map[string]string) {
+	if label == "" {
+		return nil, nil
+	}
+	return b.get(label)
+}
+
+// rollback restores the trees as they were before the batch.
+func (b *Batch) rollback() {
+	for _, tr := range b.trees {
+		sn := tr.snap
+		for i := len(sn.shifts) - 1; i >= 0; i-- {
+			for _, e := range sn.shifts[i].edges {
+				e.node.shiftDepth(-sn.shifts[i].by)
+			}
+		}
+		tr.root, tr.length, tr.size = sn.root, sn.length, sn.size
+		tr.snap = nil
+	}
+}
+
+// shifted records that the depths of the nodes below the edges changed.
+func (sn *snapshot) shifted(edges []*edge, by int) {
+	sn.shifts = append(sn.shifts, shift{edges: edges, by: by})
+}
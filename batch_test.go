@@ -0,0 +1,157 @@
+package radix_test
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	. "github.com/knnat/radix"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBatch(t *testing.T) {
+	tr := (&Settings{Flags: Tsafe | Tnocolor | Tdebug, Escape: '@', Delimiter: '/'}).New()
+	assert.Nil(t, tr.Add("tomato", 1))
+	assert.Nil(t, tr.Add("tornado", 2))
+	assert.Nil(t, tr.Add("/users/@id", 3))
+
+	err := tr.Batch(func(b *Batch) error {
+		assert.Nil(t, b.Add("to", 4))
+		assert.Nil(t, b.Set("tomato", 5))
+		assert.EqualError(t, b.Add("tornado", 6), ErrEscape.Error())
+		assert.EqualError(t, b.Add("/users/@uid", 6), ErrEscape.Error())
+		b.Del("tornado")
+		n, _ := b.Get("to")
+		assert.Equal(t, 4, n.Value)
+		return nil
+	})
+	assert.Nil(t, err)
+	n, _ := tr.Get("to")
+	assert.Equal(t, 4, n.Value)
+	n, _ = tr.Get("tomato")
+	assert.Equal(t, 5, n.Value)
+	n, _ = tr.Get("tornado")
+	assert.Nil(t, n)
+
+	// Failed batches leave the tree unchanged.
+	want, length, size := tr.String(), tr.Len(), tr.Size()
+	errBatch := errors.New("batch")
+	err = tr.Batch(func(b *Batch) error {
+		assert.Nil(t, b.Add("tom", 7))
+		assert.Nil(t, b.Add("tornado", 8))
+		assert.Nil(t, b.Set("to", 9))
+		assert.Nil(t, b.Set("/users/@id", 10))
+		b.Del("tomato")
+		b.Del("to")
+		assert.Nil(t, b.Add("to", 11))
+		return errBatch
+	})
+	assert.Equal(t, errBatch, err)
+	assert.Equal(t, want, tr.String())
+	assert.Equal(t, length, tr.Len())
+	assert.Equal(t, size, tr.Size())
+	n, _ = tr.Get("to")
+	assert.Equal(t, 4, n.Value)
+	n, p := tr.Get("/users/123")
+	assert.Equal(t, 3, n.Value)
+	assert.Equal(t, "123", p["id"])
+
+	assert.Panics(t, func() {
+		tr.Batch(func(b *Batch) error {
+			b.Del("tomato")
+			panic("batch")
+		})
+	})
+	assert.Equal(t, want, tr.String())
+}
+
+func TestBatchRollback(t *testing.T) {
+	for _, flags := range []int{0, Tsafe, Tatomic} {
+		tr := (&Settings{Flags: flags | Tnocolor | Tdebug, Escape: '@', Delimiter: '/'}).New()
+		for i, l := range []string{"a", "b", "c", "tomato", "tornado", "to"} {
+			assert.Nil(t, tr.Add(l, i))
+		}
+		tr.Sort(DescLabelSort)
+		want := tr.String()
+		var nodes []*Node
+		tr.Walk(func(label string, n *Node) bool {
+			nodes = append(nodes, n)
+			return true
+		})
+		errBatch := errors.New("batch")
+		for _, fn := range []func(b *Batch){
+			// A sibling that is not the last one.
+			func(b *Batch) { b.Del("b") },
+			// Edges merged with their only child.
+			func(b *Batch) { b.Del("to"); b.Del("tomato") },
+			// Edges split, then merged again.
+			func(b *Batch) { b.Add("tom", 7); b.Del("to"); b.Del("tom"); b.Add("b", 8) },
+		} {
+			err := tr.Batch(func(b *Batch) error {
+				fn(b)
+				return errBatch
+			})
+			assert.Equal(t, errBatch, err)
+			assert.Equal(t, want, tr.String())
+			if flags == Tatomic {
+				// Nodes of published versions may be copied when read.
+				continue
+			}
+			// The same nodes are back, at their depths.
+			i := 0
+			tr.Walk(func(label string, n *Node) bool {
+				assert.True(t, nodes[i] == n, label)
+				i++
+				return true
+			})
+		}
+	}
+}
+
+func TestBatchWeights(t *testing.T) {
+	for _, flags := range []int{0, Tsafe, Tatomic} {
+		tr := (&Settings{Flags: flags, Escape: '@', Delimiter: '/'}).New()
+		assert.Nil(t, tr.Add("a", 1))
+		assert.Nil(t, tr.Add("ab", 2))
+		assert.True(t, tr.SetWeight("a", 5))
+		err := tr.Batch(func(b *Batch) error {
+			b.Del("a")
+			return errors.New("batch")
+		})
+		assert.NotNil(t, err)
+		cs := tr.TopK("", 2)
+		if assert.Len(t, cs, 2) {
+			assert.Equal(t, "a", cs[0].Label)
+			assert.Equal(t, 5.0, cs[0].Weight)
+		}
+	}
+}
+
+func TestBatchRace(t *testing.T) {
+	tr := (&Settings{Flags: Tsafe, Escape: '@', Delimiter: '/'}).New()
+	labels := []string{"romane", "romanus", "romulus", "rubens", "ruber", "rubicon", "rubicundus"}
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			tr.Batch(func(b *Batch) error {
+				for i, l := range labels {
+					b.Set(l, i)
+				}
+				return nil
+			})
+		}()
+		go func() {
+			defer wg.Done()
+			for _, l := range labels {
+				tr.Get(l)
+			}
+		}()
+	}
+	wg.Wait()
+	for i, l := range labels {
+		n, _ := tr.Get(l)
+		assert.Equal(t, i, n.Value)
+	}
+}
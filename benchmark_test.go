@@ -1,13 +1,22 @@
 package radix_test
 
 import (
+	"math/rand"
+	"net/netip"
 	"os"
+	"sort"
+	"strconv"
 	"testing"
 
-	. "github.com/gbrlsnchs/radix"
+	. "github.com/knnat/radix"
 )
 
-var benchTree = New(Tdebug)
+var (
+	benchTree = New()
+	benchDict = New()
+	benchKeys []string
+	benchSort []string
+)
 
 func TestMain(m *testing.M) {
 	benchTree.Add("romane", 1)
@@ -17,8 +26,161 @@ func TestMain(m *testing.M) {
 	benchTree.Add("ruber", 5)
 	benchTree.Add("rubicon", 6)
 	benchTree.Add("rubicundus", 7)
+
+	// A wide dictionary, whose nodes hold up to one edge per printable byte.
+	const chars = "!\"#$%&'()*+,-.0123456789:;<=>?ABCDEFGHIJKLMNOPQRSTUVWXYZ[\\]^_`abcdefghijklmnopqrstuvwxyz{|}~"
+	rnd := rand.New(rand.NewSource(1))
+	for len(benchKeys) < 100000 {
+		b := make([]byte, 4+rnd.Intn(12))
+		for i := range b {
+			b[i] = chars[rnd.Intn(len(chars))]
+		}
+		k := string(b)
+		if benchDict.Add(k, len(benchKeys)) == nil {
+			benchKeys = append(benchKeys, k)
+		}
+	}
+	benchSort = append(benchSort, benchKeys...)
+	sort.Strings(benchSort)
 	os.Exit(m.Run())
 }
 
 func BenchmarkTree(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		benchTree.Get("rubicundus")
+	}
+}
+
+func BenchmarkGetWide(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		benchDict.Get(benchKeys[i%len(benchKeys)])
+	}
+}
+
+func BenchmarkAddWide(b *testing.B) {
+	b.ReportAllocs()
+	var tr *Tree
+	for i := 0; i < b.N; i++ {
+		if i%len(benchKeys) == 0 {
+			tr = New()
+		}
+		tr.Add(benchKeys[i%len(benchKeys)], i)
+	}
+}
+
+func BenchmarkAddSorted(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		tr := New()
+		for j, k := range benchSort {
+			tr.Add(k, j)
+		}
+	}
+}
+
+func BenchmarkBuilder(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		bd := NewBuilder(len(benchSort))
+		for j, k := range benchSort {
+			bd.Add(k, j)
+		}
+		bd.Tree()
+	}
+}
+
+func BenchmarkGetUnderWrites(b *testing.B) {
+	for _, flags := range []int{Tsafe, Tatomic} {
+		name := "Tsafe"
+		if flags == Tatomic {
+			name = "Tatomic"
+		}
+		b.Run(name, func(b *testing.B) {
+			tr := (&Settings{Flags: flags, Escape: '@', Delimiter: '/'}).New()
+			for i, k := range benchKeys[:10000] {
+				tr.Add(k, i)
+			}
+			done := make(chan struct{})
+			go func() {
+				for i := 0; ; i++ {
+					select {
+					case <-done:
+						return
+					default:
+						tr.Set(benchKeys[i%10000], i)
+					}
+				}
+			}()
+			b.ReportAllocs()
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				for i := 0; pb.Next(); i++ {
+					tr.Get(benchKeys[i%10000])
+				}
+			})
+			b.StopTimer()
+			close(done)
+		})
+	}
+}
+
+func BenchmarkIntTreeGet(b *testing.B) {
+	it := NewIntTree()
+	for i := uint64(0); i < 100000; i++ {
+		it.Insert(i*3, i)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		it.Get(uint64(i % 300000))
+	}
+}
+
+func BenchmarkPrefixTableLookup(b *testing.B) {
+	tab := NewPrefixTable()
+	r := rand.New(rand.NewSource(1))
+	var addrs []netip.Addr
+	for i := 0; i < 100000; i++ {
+		var ip [16]byte
+		r.Read(ip[:])
+		a := netip.AddrFrom16(ip)
+		p, _ := a.Prefix(16 + r.Intn(49))
+		tab.Insert(p, i)
+		addrs = append(addrs, a)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		tab.Lookup(addrs[i%len(addrs)])
+	}
+}
+
+func BenchmarkSplitNearRoot(b *testing.B) {
+	for _, flags := range []int{Tsafe, Tatomic} {
+		name := "Tsafe"
+		if flags == Tatomic {
+			name = "Tatomic"
+		}
+		b.Run(name, func(b *testing.B) {
+			tr := (&Settings{Flags: flags, Escape: '@', Delimiter: '/'}).New()
+			for i := 0; i < 200000; i++ {
+				tr.Add("abcdef"+strconv.Itoa(i), i)
+			}
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				tr.Add("abc", i)
+				tr.Del("abc")
+			}
+		})
+	}
+}
+
+func BenchmarkMatcher(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		benchDict.Matcher()
+	}
 }
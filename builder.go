@@ -1,13 +1,235 @@
 package radix
 
-import (
-	"strings"
+import "errors"
 
-	"github.com/gbrlsnchs/color"
+// chunkSize is the number of nodes and edges allocated at once
+// by a builder once its preallocated ones are exhausted.
+const chunkSize = 256
+
+var (
+	// ErrUnsorted indicates a label added out of ascending order.
+	ErrUnsorted = errors.New("labels out of order")
+
+	// ErrDuplicate indicates a label that has already been added.
+	ErrDuplicate = errors.New("duplicate label")
 )
 
-type builder struct {
-	*strings.Builder
-	colors [4]color.Color
-	debug  bool
+// Builder builds a tree in a single pass from labels added in ascending order.
+//
+// As labels are sorted, only the rightmost path of the tree is ever modified,
+// so nodes are finished as soon as the builder leaves them, and their edges
+// are stored with exact sizes.
+type Builder struct {
+	tr   *Tree
+	prev string
+	path []*edge   // edges of the rightmost path
+	ends []int     // label lengths at the end of each edge of the path
+	kids [][]*edge // edges of the unfinished nodes, by depth
+
+	// Preallocated memory.
+	nodes []Node
+	edges []edge
+	slab  []*edge
+}
+
+// NewBuilder creates a builder for a tree with the settings.
+//
+// The number of labels, if known, is used to preallocate
+// all nodes and edges at once.
+func (s *Settings) NewBuilder(n int) *Builder {
+	b := &Builder{
+		tr:   s.New(),
+		kids: make([][]*edge, 1),
+	}
+	if n > 0 {
+		// A tree with n values has at most 2n nodes, its root included.
+		b.nodes = make([]Node, 0, 2*n)
+		b.edges = make([]edge, 0, 2*n)
+		b.slab = make([]*edge, 0, 2*n)
+	}
+	return b
+}
+
+// NewBuilder creates a builder for a tree with the default settings.
+func NewBuilder(n int) *Builder {
+	return defaults.NewBuilder(n)
+}
+
+// Add adds a new node to the tree being built.
+//
+// Labels must be added in strictly ascending order, once normalized by the
+// settings' key normalizer, if any, otherwise ErrUnsorted or ErrDuplicate
+// are returned and the label is ignored. Escape symbols are checked the
+// same way as by (*Tree).Add.
+func (b *Builder) Add(label string, v interface{}) error {
+	// No empty strings or interfaces allowed.
+	if label == "" || v == nil {
+		return nil
+	}
+	tr := b.tr
+	label = tr.normalize(label)
+	if err := tr.validate(label); err != nil {
+		return err
+	}
+	if len(b.path) > 0 {
+		if label == b.prev {
+			return ErrDuplicate
+		}
+		if label < b.prev {
+			return ErrUnsorted
+		}
+	}
+	// Labels are sorted, so the new label can't be a prefix of the previous one,
+	// thus the new node is always a leaf.
+	lcp := tr.common(b.prev, label)
+	if err := b.check(label, lcp); err != nil {
+		return err
+	}
+	for len(b.path) > 0 {
+		d := len(b.path)
+		top := b.path[d-1]
+		if b.ends[d-1] <= lcp {
+			break
+		}
+		b.finish(d)
+		// Break the edge into the common prefix and its remaining slice.
+		//
+		// Example:
+		// 	(root) -> ("tomato", v1)
+		// 	then add "tornado"
+		// 	(root) -> ("to", nil) -> ("mato", v1)
+		// 	                      +> ("rnado", v2)
+		if start := b.ends[d-1] - len(top.label); start < lcp {
+			e := b.newEdge(top.label[lcp-start:], top.node)
+			top.label = top.label[:lcp-start]
+			top.node = b.newNode(nil)
+			b.ends[d-1] = lcp
+			b.kids[d] = append(b.kids[d], e)
+			tr.length++
+			break
+		}
+		b.path = b.path[:d-1]
+		b.ends = b.ends[:d-1]
+	}
+	d := len(b.path)
+	e := b.newEdge(label[lcp:], b.newNode(v))
+	b.kids[d] = append(b.kids[d], e)
+	b.path = append(b.path, e)
+	b.ends = append(b.ends, len(label))
+	if len(b.kids) <= d+1 {
+		b.kids = append(b.kids, nil)
+	}
+	tr.length++
+	tr.size += len(e.label)
+	b.prev = label
+	return nil
+}
+
+// check checks whether a label conflicts with the ones already
+// added, the same way as (*Tree).Add does, before adding it.
+func (b *Builder) check(label string, lcp int) error {
+	tr := b.tr
+	// The label without a trailing delimiter or its optional last segment
+	// would be a prefix of the label, so it would be on the rightmost path.
+	other := -1
+	if n := len(label) - 1; label[n] == tr.delim && tr.endsInPlaceholder(label[:n]) {
+		other = n
+	} else if base, ok := tr.trimOptional(label); ok {
+		other = len(base)
+	}
+	for i, end := range b.ends {
+		if end == other && b.path[i].node.Value != nil {
+			return ErrEscape
+		}
+	}
+	if label[lcp] != tr.escape {
+		return nil
+	}
+	// Find the edges that will be siblings of the new one.
+	d := 0
+	for d < len(b.ends) && b.ends[d] <= lcp {
+		d++
+	}
+	siblings := b.kids[d]
+	if d < len(b.path) {
+		if start := b.ends[d] - len(b.path[d].label); start < lcp {
+			siblings = []*edge{{label: b.path[d].label[lcp-start:]}}
+		}
+	}
+	for _, e := range siblings {
+		if e.label[0] == tr.escape && tr.ambiguous(e.label, label[lcp:]) {
+			return ErrEscape
+		}
+	}
+	return nil
+}
+
+// Tree finishes building and returns the tree.
+//
+// The builder must not be used afterwards.
+func (b *Builder) Tree() *Tree {
+	for d := len(b.path); d >= 0; d-- {
+		b.finish(d)
+	}
+	tr := b.tr
+	tr.root.setDepth(0)
+	if tr.atomic {
+		tr.publish()
+	}
+	*b = Builder{}
+	return tr
+}
+
+// finish sets the edges of the unfinished node at depth d.
+func (b *Builder) finish(d int) {
+	kids := b.kids[d]
+	if len(kids) == 0 {
+		return
+	}
+	n := b.tr.root
+	if d > 0 {
+		n = b.path[d-1].node
+	}
+	if len(b.slab)+len(kids) > cap(b.slab) {
+		size := chunkSize
+		if len(kids) > size {
+			size = len(kids)
+		}
+		b.slab = make([]*edge, 0, size)
+	}
+	edges := b.slab[len(b.slab) : len(b.slab)+len(kids) : len(b.slab)+len(kids)]
+	b.slab = b.slab[:len(b.slab)+len(kids)]
+	// Edges are sorted, except for the ones with a placeholder,
+	// which have to be placed last.
+	i := 0
+	for _, e := range kids {
+		if e.label[0] != b.tr.escape {
+			edges[i] = e
+			i++
+		}
+	}
+	for _, e := range kids {
+		if e.label[0] == b.tr.escape {
+			edges[i] = e
+			i++
+		}
+	}
+	n.setEdges(edges...)
+	b.kids[d] = kids[:0]
+}
+
+func (b *Builder) newNode(v interface{}) *Node {
+	if len(b.nodes) == cap(b.nodes) {
+		b.nodes = make([]Node, 0, chunkSize)
+	}
+	b.nodes = append(b.nodes, Node{Value: v})
+	return &b.nodes[len(b.nodes)-1]
+}
+
+func (b *Builder) newEdge(label string, n *Node) *edge {
+	if len(b.edges) == cap(b.edges) {
+		b.edges = make([]edge, 0, chunkSize)
+	}
+	b.edges = append(b.edges, edge{label: label, node: n})
+	return &b.edges[len(b.edges)-1]
 }
@@ -0,0 +1,64 @@
+package radix_test
+
+import (
+	"sort"
+	"testing"
+
+	. "github.com/knnat/radix"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuilder(t *testing.T) {
+	labels := []string{
+		"romane", "romanus", "romulus", "rubens", "ruber", "rubicon", "rubicundus",
+		"to", "tomato", "tornado", "tornados", "/users", "/users/@id",
+		"/users/all", "/users/all/friends",
+	}
+	for c := 'A'; c <= 'Z'; c++ {
+		labels = append(labels, string(c)+"x")
+	}
+	sort.Strings(labels)
+	settings := &Settings{Flags: Tnocolor | Tdebug, Escape: '@', Delimiter: '/'}
+	want := settings.New()
+	for i, l := range labels {
+		assert.Nil(t, want.Add(l, i))
+	}
+	for _, n := range []int{0, 1, len(labels)} {
+		b := settings.NewBuilder(n)
+		for i, l := range labels {
+			assert.Nil(t, b.Add(l, i))
+		}
+		got := b.Tree()
+		assert.Equal(t, want.String(), got.String())
+		assert.Equal(t, want.Len(), got.Len())
+		assert.Equal(t, want.Size(), got.Size())
+		for i, l := range labels {
+			n, _ := got.Get(l)
+			if assert.NotNil(t, n, l) {
+				assert.Equal(t, i, n.Value)
+				wn, _ := want.Get(l)
+				assert.Equal(t, wn.Depth(), n.Depth())
+			}
+		}
+		n, p := got.Get("/users/123")
+		assert.Equal(t, sort.SearchStrings(labels, "/users/@id"), n.Value)
+		assert.Equal(t, "123", p["id"])
+	}
+}
+
+func TestBuilderErrors(t *testing.T) {
+	b := NewBuilder(0)
+	assert.Nil(t, b.Add("/@abc", 0))
+	assert.EqualError(t, b.Add("/@abc", 1), ErrDuplicate.Error())
+	assert.EqualError(t, b.Add("/@ab", 1), ErrUnsorted.Error())
+	assert.EqualError(t, b.Add("/@abc/", 1), ErrEscape.Error())
+	assert.EqualError(t, b.Add("/@abcd", 1), ErrEscape.Error())
+	assert.EqualError(t, b.Add("/@efg", 1), ErrEscape.Error())
+	assert.EqualError(t, b.Add("/abc@abc@", 1), ErrInvalid.Error())
+	assert.Nil(t, b.Add("/abc", 1))
+	tr := b.Tree()
+	assert.Equal(t, 4, tr.Len())
+	n, p := tr.Get("/123")
+	assert.Equal(t, 0, n.Value)
+	assert.Equal(t, "123", p["abc"])
+}
@@ -0,0 +1,40 @@
+package radix
+
+import "unsafe"
+
+// AddBytes is like Add, but for a label held by a byte slice.
+// The tree keeps a copy of the label, not the slice.
+func (tr *Tree) AddBytes(label []byte, v interface{}) error {
+	return tr.Add(string(label), v)
+}
+
+// GetBytes is like Get, but for a label held by a byte slice.
+//
+// It doesn't allocate, except for the params, whose values are copied
+// so that they don't change along with the slice.
+func (tr *Tree) GetBytes(label []byte) (*Node, map[string]string) {
+	n, params := tr.Get(unsafeString(label))
+	for k, v := range params {
+		params[k] = string([]byte(v))
+	}
+	return n, params
+}
+
+// DelBytes is like Del, but for a label held by a byte slice.
+func (tr *Tree) DelBytes(label []byte) {
+	tr.Del(unsafeString(label))
+}
+
+// LongestPrefixBytes is like LongestPrefix, but for a byte slice,
+// returning the part of the slice that the label found matches.
+// It doesn't allocate, unless the tree has a key normalizer.
+func (tr *Tree) LongestPrefixBytes(b []byte) ([]byte, *Node, bool) {
+	label, n, ok := tr.LongestPrefix(unsafeString(b))
+	return b[:len(label)], n, ok
+}
+
+// unsafeString returns a string sharing the bytes of b, which must
+// neither be modified nor kept by the tree while the string is in use.
+func unsafeString(b []byte) string {
+	return *(*string)(unsafe.Pointer(&b))
+}
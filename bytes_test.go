@@ -0,0 +1,87 @@
+package radix_test
+
+import (
+	"testing"
+
+	. "github.com/knnat/radix"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBytes(t *testing.T) {
+	for _, flags := range []int{0, Tsafe, Tatomic} {
+		tr := (&Settings{Flags: flags, Escape: '@', Delimiter: '/'}).New()
+		buf := []byte("/users/@id/posts")
+		assert.Nil(t, tr.AddBytes(buf, 1))
+		copy(buf, "/files/@xx/posts")
+		assert.Nil(t, tr.AddBytes([]byte("/users/new"), 2))
+		assert.Nil(t, tr.AddBytes([]byte("/u"), 3))
+
+		n, params := tr.Get("/users/42/posts")
+		if assert.NotNil(t, n) {
+			assert.Equal(t, 1, n.Value)
+			assert.Equal(t, map[string]string{"id": "42"}, params)
+		}
+		n, _ = tr.Get("/files/42/posts")
+		assert.Nil(t, n)
+
+		buf = []byte("/users/42/posts")
+		n, params = tr.GetBytes(buf)
+		copy(buf, "/users/xx/posts")
+		if assert.NotNil(t, n) {
+			assert.Equal(t, 1, n.Value)
+			assert.Equal(t, map[string]string{"id": "42"}, params)
+		}
+		n, params = tr.GetBytes([]byte("/users/new"))
+		if assert.NotNil(t, n) {
+			assert.Equal(t, 2, n.Value)
+			assert.Nil(t, params)
+		}
+
+		prefix, n, ok := tr.LongestPrefixBytes([]byte("/users/newest"))
+		assert.True(t, ok)
+		assert.Equal(t, "/users/new", string(prefix))
+		assert.Equal(t, 2, n.Value)
+		label, n, ok := tr.LongestPrefix("/usr")
+		assert.True(t, ok)
+		assert.Equal(t, "/u", label)
+		assert.Equal(t, 3, n.Value)
+		_, _, ok = tr.LongestPrefix("/x")
+		assert.False(t, ok)
+
+		tr.DelBytes([]byte("/users/new"))
+		n, _ = tr.GetBytes([]byte("/users/new"))
+		assert.Nil(t, n)
+		prefix, n, ok = tr.LongestPrefixBytes([]byte("/users/newest"))
+		assert.True(t, ok)
+		assert.Equal(t, "/u", string(prefix))
+		assert.Equal(t, 3, n.Value)
+	}
+}
+
+func TestLongestPrefixNormalized(t *testing.T) {
+	tr := (&Settings{Escape: '@', Delimiter: '/', KeyNormalizer: FoldUnicode}).New()
+	assert.Nil(t, tr.Add("/straße", 1))
+	assert.Nil(t, tr.Add("/straße/nord", 2))
+	// The capital sharp s is longer than the label's lower case one.
+	prefix, n, ok := tr.LongestPrefixBytes([]byte("/STRAẞE/Nordost"))
+	assert.True(t, ok)
+	assert.Equal(t, "/STRAẞE/Nord", string(prefix))
+	assert.Equal(t, 2, n.Value)
+	label, n, ok := tr.LongestPrefix("/Straße/Süd")
+	assert.True(t, ok)
+	assert.Equal(t, "/Straße", label)
+	assert.Equal(t, 1, n.Value)
+}
+
+func TestBytesAllocs(t *testing.T) {
+	tr := New()
+	for _, l := range []string{"/a", "/a/b", "/a/b/c"} {
+		assert.Nil(t, tr.Add(l, l))
+	}
+	key := []byte("/a/b/c/d")
+	allocs := testing.AllocsPerRun(100, func() {
+		tr.GetBytes(key[:6])
+		tr.LongestPrefixBytes(key)
+	})
+	assert.Equal(t, 0.0, allocs)
+}
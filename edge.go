@@ -9,7 +9,7 @@ type edge struct {
 	node  *Node
 }
 
-func (e *edge) writeTo(bd *builder, tabList []bool) {
+func (e *edge) writeTo(bd *printer, tabList []bool) {
 	length := len(tabList)
 	isLast, tlist := tabList[length-1], tabList[:length-1]
 	for _, hasTab := range tlist {
@@ -35,10 +35,11 @@ func (e *edge) writeTo(bd *builder, tabList []bool) {
 	}
 	bd.WriteByte('\n')
 	for i, next := range e.node.edges {
-		if len(tabList) < next.node.depth { // runs only for the first edge
+		// The edge's node is at depth length, so its children are at length+1.
+		if len(tabList) <= length { // runs only for the first edge
 			tabList = append(tabList, i == len(e.node.edges)-1)
 		} else {
-			tabList[next.node.depth-1] = i == len(e.node.edges)-1
+			tabList[length] = i == len(e.node.edges)-1
 		}
 		next.writeTo(bd, tabList)
 	}
@@ -0,0 +1,125 @@
+package radix
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// StepKind is the kind of a step of a lookup.
+type StepKind uint8
+
+const (
+	// StepVisit is a node visited with the rest of the label.
+	StepVisit StepKind = iota
+	// StepEdge is an edge tried, from the label's part it leads to.
+	StepEdge
+	// StepParam is a value captured by a placeholder.
+	StepParam
+	// StepReject is an edge, or a node, which can't match the label.
+	StepReject
+)
+
+// Step is a step of a lookup.
+type Step struct {
+	Kind   StepKind
+	Depth  int    // depth of the node visited or the edge's parent
+	Edge   string // the part of the edge's label tried, if any
+	Label  string // the part of the label left to match
+	Param  string // name of the placeholder, for StepParam
+	Value  string // value captured, for StepParam
+	Reason string // why the edge or node was rejected, for StepReject
+}
+
+// Trace is the trace of a lookup, as returned by Explain.
+type Trace struct {
+	Label  string
+	Steps  []Step
+	Node   *Node             // the node found, if any
+	Params map[string]string // the params of the node found
+}
+
+// Explain looks a label up as Get does and returns a trace of the
+// nodes visited, the edges tried, the reasons they were rejected and
+// the values captured by placeholders along the way.
+func (tr *Tree) Explain(label string) *Trace {
+	t := &Trace{Label: label}
+	if label == "" {
+		return t
+	}
+	defer tr.runlock()
+	s := tr.newSearch(label)
+	s.trace = t
+	t.Node = tr.lookup(tr.rlock().root, s.key, &s)
+	if t.Node != nil {
+		t.Node = t.Node.at(s.depth)
+	}
+	t.Params = paramMap(t.Node, s.params)
+	return t
+}
+
+func (t *Trace) add(s Step) {
+	t.Steps = append(t.Steps, s)
+}
+
+// reject adds a step rejecting an edge of a node at the given depth.
+func (t *Trace) reject(depth int, slice, reason string) {
+	t.add(Step{Kind: StepReject, Depth: depth, Edge: slice, Reason: reason})
+}
+
+// String returns the trace with a line per step, indented by depth,
+// followed by the outcome of the lookup.
+//
+// Example:
+//
+//	lookup "/users/x"
+//	visit "/users/x"
+//	try "/users/" for "/users/x"
+//	  visit "x"
+//	  reject: no edge starting with 'x'
+//	  try "@id:int" for "x"
+//	  reject "@id:int": "x" doesn't match the constraint :int
+//	reject: no edge starting with '@'
+//	not found
+func (t *Trace) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "lookup %q\n", t.Label)
+	for _, s := range t.Steps {
+		b.WriteString(strings.Repeat("  ", s.Depth))
+		switch s.Kind {
+		case StepVisit:
+			fmt.Fprintf(&b, "visit %q\n", s.Label)
+		case StepEdge:
+			fmt.Fprintf(&b, "try %q for %q\n", s.Edge, s.Label)
+		case StepParam:
+			fmt.Fprintf(&b, "capture %s = %q\n", s.Param, s.Value)
+		case StepReject:
+			if s.Edge != "" {
+				fmt.Fprintf(&b, "reject %q: %s\n", s.Edge, s.Reason)
+			} else {
+				fmt.Fprintf(&b, "reject: %s\n", s.Reason)
+			}
+		}
+	}
+	if t.Node == nil {
+		b.WriteString("not found\n")
+		return b.String()
+	}
+	b.WriteString("found")
+	if len(t.Params) > 0 {
+		keys := make([]string, 0, len(t.Params))
+		for k := range t.Params {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		b.WriteString(", with params")
+		for i, k := range keys {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			fmt.Fprintf(&b, " %s = %q", k, t.Params[k])
+		}
+	}
+	b.WriteByte('\n')
+	return b.String()
+}
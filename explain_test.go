@@ -0,0 +1,58 @@
+package radix_test
+
+import (
+	"testing"
+
+	. "github.com/knnat/radix"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExplain(t *testing.T) {
+	tr := New()
+	for i, l := range []string{"/users/@id:int", "/users/new/edit", "/posts"} {
+		assert.Nil(t, tr.Add(l, i))
+	}
+	trace := tr.Explain("/users/12")
+	if assert.NotNil(t, trace.Node) {
+		assert.Equal(t, 0, trace.Node.Value)
+		assert.Equal(t, map[string]string{"id": "12"}, trace.Params)
+	}
+	assert.Equal(t, `lookup "/users/12"
+visit "/users/12"
+try "/" for "/users/12"
+  visit "users/12"
+  try "users/" for "users/12"
+    visit "12"
+    reject: no edge starting with '1'
+    try "@id:int" for "12"
+    capture id = "12"
+      visit ""
+found, with params id = "12"
+`, trace.String())
+
+	trace = tr.Explain("/users/new")
+	assert.Nil(t, trace.Node)
+	assert.Nil(t, trace.Params)
+	var reasons []string
+	for _, s := range trace.Steps {
+		if s.Kind == StepReject {
+			reasons = append(reasons, s.Edge+": "+s.Reason)
+		}
+	}
+	assert.Equal(t, []string{
+		"/edit: label exhausted in the middle of the edge",
+		`@id:int: "new" doesn't match the constraint :int`,
+		": no edge starting with '@'",
+		": no edge starting with '@'",
+	}, reasons)
+	assert.Contains(t, trace.String(), "not found\n")
+
+	tr = (&Settings{Delimiter: '/', Syntax: BraceSyntax}).New()
+	assert.Nil(t, tr.Add("/files/{name}.json", 1))
+	trace = tr.Explain("/files/a/b.json")
+	assert.Nil(t, trace.Node)
+	assert.Contains(t, trace.String(), `reject "{name}.json": segment "a" doesn't end with ".json"`)
+	trace = tr.Explain("/files/a.json/b")
+	assert.Contains(t, trace.String(), `reject "{name}.json": nothing matched below, and the placeholder doesn't match across the delimiter '/'`)
+	assert.Equal(t, "lookup \"\"\nnot found\n", tr.Explain("").String())
+}
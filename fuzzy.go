@@ -0,0 +1,147 @@
+package radix
+
+import (
+	"sort"
+	"unicode/utf8"
+)
+
+// FuzzyMatch is a label found by a fuzzy lookup.
+type FuzzyMatch struct {
+	Label    string
+	Node     *Node
+	Distance int
+}
+
+// Fuzzy returns all labels within the Levenshtein distance maxDist
+// of the query, ordered by distance and then by label.
+//
+// Distances count bytes or, with Trunes, runes. Placeholders
+// are not expanded, labels are compared as they were added.
+func (tr *Tree) Fuzzy(query string, maxDist int) []FuzzyMatch {
+	return tr.fuzzy(query, maxDist, false)
+}
+
+// FuzzyDamerau is like Fuzzy, but also counts a transposition
+// of two adjacent bytes, or runes, as a single edit.
+func (tr *Tree) FuzzyDamerau(query string, maxDist int) []FuzzyMatch {
+	return tr.fuzzy(query, maxDist, true)
+}
+
+func (tr *Tree) fuzzy(query string, maxDist int, damerau bool) []FuzzyMatch {
+	if maxDist < 0 {
+		return nil
+	}
+	defer tr.runlock()
+	f := &fuzzy{
+		query:   units(tr.fold(query), tr.runes),
+		max:     maxDist,
+		damerau: damerau,
+		runes:   tr.runes,
+		mins:    []int{0},
+	}
+	f.rows = [][]int{make([]int, len(f.query)+1)}
+	for j := range f.rows[0] {
+		f.rows[0][j] = j
+	}
+	f.walk(tr.rlock().root, 0)
+	sort.SliceStable(f.matches, func(i, j int) bool {
+		return f.matches[i].Distance < f.matches[j].Distance
+	})
+	return f.matches
+}
+
+// units returns the bytes of s or, if runes is true, its runes.
+func units(s string, runes bool) []rune {
+	if runes {
+		return []rune(s)
+	}
+	u := make([]rune, len(s))
+	for i := range u {
+		u[i] = rune(s[i])
+	}
+	return u
+}
+
+// fuzzy walks a tree computing, for each byte, or rune, of the labels,
+// a row of the edit distance matrix between the label and the query.
+//
+// Whole subtrees are pruned as soon as all values of a row exceed the
+// maximum distance, as rows never get lower while going down the tree.
+// Transpositions reach two rows back, so the previous row is checked too.
+type fuzzy struct {
+	query   []rune
+	max     int
+	damerau bool
+	runes   bool    // whether labels are compared by runes rather than bytes
+	rows    [][]int // rows[i] is the row for the label's first i units
+	mins    []int   // lowest value of each row
+	label   []rune  // the label's bytes or runes
+	text    []byte  // the label itself
+	matches []FuzzyMatch
+}
+
+func (f *fuzzy) walk(n *Node, depth int) {
+	units, size := len(f.label), len(f.text)
+	if d := f.rows[units][len(f.query)]; n.Value != nil && d <= f.max {
+		f.matches = append(f.matches, FuzzyMatch{
+			Label:    string(f.text),
+			Node:     n.at(depth),
+			Distance: d,
+		})
+	}
+	for e := n.edgeAfter(nil); e != nil; e = n.edgeAfter(e) {
+		ok := true
+		for i := 0; i < len(e.label) && ok; {
+			r, w := rune(e.label[i]), 1
+			if f.runes {
+				r, w = utf8.DecodeRuneInString(e.label[i:])
+			}
+			f.label = append(f.label, r)
+			i += w
+			ok = f.step()
+		}
+		if ok {
+			f.text = append(f.text, e.label...)
+			f.walk(e.node, depth+1)
+		}
+		f.label, f.text = f.label[:units], f.text[:size]
+	}
+}
+
+// step computes the row for the label's last unit and
+// reports whether the label may still lead to a match.
+func (f *fuzzy) step() bool {
+	i := len(f.label)
+	if len(f.rows) <= i {
+		f.rows = append(f.rows, make([]int, len(f.query)+1))
+		f.mins = append(f.mins, 0)
+	}
+	prev, row := f.rows[i-1], f.rows[i]
+	c := f.label[i-1]
+	row[0] = i
+	min := row[0]
+	for j := 1; j <= len(f.query); j++ {
+		cost := 1
+		if f.query[j-1] == c {
+			cost = 0
+		}
+		d := prev[j-1] + cost // substitution
+		if v := prev[j] + 1; v < d {
+			d = v // deletion
+		}
+		if v := row[j-1] + 1; v < d {
+			d = v // insertion
+		}
+		if f.damerau && i > 1 && j > 1 && c == f.query[j-2] && f.label[i-2] == f.query[j-1] {
+			if v := f.rows[i-2][j-2] + 1; v < d {
+				d = v // transposition
+			}
+		}
+		row[j] = d
+		if d < min {
+			min = d
+		}
+	}
+	f.mins[i] = min
+	return min <= f.max || f.damerau && f.mins[i-1] < f.max
+}
@@ -0,0 +1,117 @@
+package radix_test
+
+import (
+	"testing"
+
+	. "github.com/knnat/radix"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFuzzy(t *testing.T) {
+	tr := New()
+	for i, l := range []string{"romane", "romanus", "romulus", "rubens", "ruber", "rubicon", "rubicundus", "rub"} {
+		assert.Nil(t, tr.Add(l, i))
+	}
+	labels := func(matches []FuzzyMatch) []string {
+		var labels []string
+		for _, m := range matches {
+			labels = append(labels, m.Label)
+		}
+		return labels
+	}
+	distances := func(matches []FuzzyMatch) []int {
+		var distances []int
+		for _, m := range matches {
+			distances = append(distances, m.Distance)
+		}
+		return distances
+	}
+
+	m := tr.Fuzzy("ruber", 0)
+	assert.Equal(t, []string{"ruber"}, labels(m))
+	assert.Equal(t, 4, m[0].Node.Value)
+
+	m = tr.Fuzzy("rubes", 1)
+	assert.Equal(t, []string{"rubens", "ruber"}, labels(m))
+
+	m = tr.Fuzzy("rubes", 2)
+	assert.Equal(t, []string{"rubens", "ruber", "rub"}, labels(m))
+	assert.Equal(t, []int{1, 1, 2}, distances(m))
+
+	m = tr.Fuzzy("romnae", 1)
+	assert.Nil(t, m)
+	m = tr.Fuzzy("romnae", 2)
+	assert.Equal(t, []string{"romane"}, labels(m))
+	m = tr.FuzzyDamerau("romnae", 1)
+	assert.Equal(t, []string{"romane"}, labels(m))
+	assert.Equal(t, []int{1}, distances(m))
+
+	// Transposition right after pruning would have happened on a single row.
+	m = tr.FuzzyDamerau("rbu", 1)
+	assert.Equal(t, []string{"rub"}, labels(m))
+
+	m = tr.Fuzzy("", 3)
+	assert.Equal(t, []string{"rub"}, labels(m))
+	assert.Nil(t, tr.Fuzzy("rub", -1))
+}
+
+func TestFuzzyBruteForce(t *testing.T) {
+	tr := New()
+	keys := benchKeys[:2000]
+	for i, k := range keys {
+		tr.Add(k, i)
+	}
+	distance := func(a, b string, damerau bool) int {
+		d := make([][]int, len(a)+1)
+		for i := range d {
+			d[i] = make([]int, len(b)+1)
+			d[i][0] = i
+		}
+		for j := range d[0] {
+			d[0][j] = j
+		}
+		for i := 1; i <= len(a); i++ {
+			for j := 1; j <= len(b); j++ {
+				cost := 1
+				if a[i-1] == b[j-1] {
+					cost = 0
+				}
+				d[i][j] = d[i-1][j-1] + cost
+				if d[i-1][j]+1 < d[i][j] {
+					d[i][j] = d[i-1][j] + 1
+				}
+				if d[i][j-1]+1 < d[i][j] {
+					d[i][j] = d[i][j-1] + 1
+				}
+				if damerau && i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] && d[i-2][j-2]+1 < d[i][j] {
+					d[i][j] = d[i-2][j-2] + 1
+				}
+			}
+		}
+		return d[len(a)][len(b)]
+	}
+	for _, damerau := range []bool{false, true} {
+		for _, q := range keys[:20] {
+			q = q[:len(q)-1] + "x"
+			if len(q) > 3 {
+				q = q[:1] + q[2:3] + q[1:2] + q[3:] // swap two bytes
+			}
+			var got []FuzzyMatch
+			if damerau {
+				got = tr.FuzzyDamerau(q, 3)
+			} else {
+				got = tr.Fuzzy(q, 3)
+			}
+			want := make(map[string]int)
+			for _, k := range keys {
+				if d := distance(k, q, damerau); d <= 3 {
+					want[k] = d
+				}
+			}
+			assert.Equal(t, len(want), len(got), q)
+			for _, m := range got {
+				assert.Equal(t, want[m.Label], m.Distance, q)
+			}
+		}
+	}
+}
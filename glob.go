@@ -0,0 +1,244 @@
+package radix
+
+import (
+	"path"
+	"regexp"
+	"regexp/syntax"
+)
+
+// MatchGlob returns all labels matching the pattern, in ascending order.
+//
+// The pattern syntax is the same as path.Match's, with the tree's
+// delimiter as separator, except that patterns match bytes, not runes:
+//
+//	'*'         matches any sequence of non-delimiter bytes
+//	'?'         matches any single non-delimiter byte
+//	'[' [ '^' ] { range } ']'
+//	            matches a byte in, or with '^' not in, the ranges
+//	'\\' c      matches byte c
+//
+// The tree is walked with the pattern's automaton, so subtrees are
+// skipped as soon as their labels can't match the pattern anymore.
+// Placeholders are not expanded, labels are compared as they were added.
+// The only possible error is path.ErrBadPattern.
+func (tr *Tree) MatchGlob(pattern string) ([]Entry, error) {
+	g, err := compileGlob(tr.fold(pattern), tr.delim)
+	if err != nil {
+		return nil, err
+	}
+	defer tr.runlock()
+	tnode, label, depth := tr.seek(tr.rlock().root, g.literal)
+	if tnode == nil {
+		return nil, nil
+	}
+	g.sets = [][]bool{g.closure(make([]bool, len(g.items)+1), 0)}
+	for _, c := range label {
+		g.label = append(g.label, c)
+		if !g.step() {
+			return nil, nil
+		}
+	}
+	g.walk(tnode, depth)
+	return g.entries, nil
+}
+
+// MatchRegexp returns all labels matching re, in ascending order.
+//
+// If re is anchored at the beginning of the text, only labels
+// starting with its literal prefix are tested.
+// Placeholders are not expanded, labels are compared as they were added,
+// and as the tree's key normalizer mapped them, if it has one.
+func (tr *Tree) MatchRegexp(re *regexp.Regexp) []Entry {
+	var entries []Entry
+	tr.WalkPrefix(regexpPrefix(re), func(label string, n *Node) bool {
+		if re.MatchString(label) {
+			entries = append(entries, Entry{Label: label, Node: n})
+		}
+		return true
+	})
+	return entries
+}
+
+// regexpPrefix returns the literal text all matches of re start with,
+// if re is anchored at the beginning of the text.
+func regexpPrefix(re *regexp.Regexp) string {
+	r, err := syntax.Parse(re.String(), syntax.Perl)
+	if err != nil {
+		return ""
+	}
+	r = r.Simplify()
+	if r.Op != syntax.OpConcat || r.Sub[0].Op != syntax.OpBeginText {
+		return ""
+	}
+	var prefix []rune
+	for _, sub := range r.Sub[1:] {
+		if sub.Op != syntax.OpLiteral || sub.Flags&syntax.FoldCase != 0 {
+			break
+		}
+		prefix = append(prefix, sub.Rune...)
+	}
+	return string(prefix)
+}
+
+const (
+	globByte = iota
+	globAny
+	globStar
+	globClass
+)
+
+type globItem struct {
+	kind   int
+	c      byte
+	negate bool
+	ranges [][2]byte
+}
+
+func (it *globItem) match(c byte) bool {
+	switch it.kind {
+	case globByte:
+		return c == it.c
+	case globClass:
+		for _, r := range it.ranges {
+			if r[0] <= c && c <= r[1] {
+				return !it.negate
+			}
+		}
+		return it.negate
+	}
+	return true
+}
+
+// glob walks a tree with a glob pattern's automaton, keeping the
+// set of the pattern's positions reached by each byte of the label.
+type glob struct {
+	items   []globItem
+	literal string // literal bytes the pattern starts with
+	delim   byte
+	sets    [][]bool // sets[i] is the set reached by the label's first i bytes
+	label   []byte
+	entries []Entry
+}
+
+func compileGlob(pattern string, delim byte) (*glob, error) {
+	g := &glob{delim: delim}
+	var literal []byte
+	prefix := true
+	for i := 0; i < len(pattern); i++ {
+		it := globItem{kind: globByte, c: pattern[i]}
+		switch pattern[i] {
+		case '*':
+			it.kind = globStar
+		case '?':
+			it.kind = globAny
+		case '\\':
+			if i++; i == len(pattern) {
+				return nil, path.ErrBadPattern
+			}
+			it.c = pattern[i]
+		case '[':
+			it.kind = globClass
+			if i++; i < len(pattern) && pattern[i] == '^' {
+				it.negate = true
+				i++
+			}
+			for ; ; i++ {
+				if i == len(pattern) {
+					return nil, path.ErrBadPattern
+				}
+				if pattern[i] == ']' && len(it.ranges) > 0 {
+					break
+				}
+				lo, ok := globClassByte(pattern, &i)
+				if !ok {
+					return nil, path.ErrBadPattern
+				}
+				hi := lo
+				if i+2 < len(pattern) && pattern[i+1] == '-' && pattern[i+2] != ']' {
+					i += 2
+					if hi, ok = globClassByte(pattern, &i); !ok || hi < lo {
+						return nil, path.ErrBadPattern
+					}
+				}
+				it.ranges = append(it.ranges, [2]byte{lo, hi})
+			}
+		}
+		if prefix = prefix && it.kind == globByte; prefix {
+			literal = append(literal, it.c)
+		}
+		g.items = append(g.items, it)
+	}
+	g.literal = string(literal)
+	return g, nil
+}
+
+// globClassByte returns the byte at i of a class, unescaping it.
+func globClassByte(pattern string, i *int) (byte, bool) {
+	if pattern[*i] == '\\' {
+		if *i++; *i == len(pattern) {
+			return 0, false
+		}
+	}
+	return pattern[*i], true
+}
+
+// closure adds position p to the set, along with
+// the positions reached by skipping stars.
+func (g *glob) closure(set []bool, p int) []bool {
+	set[p] = true
+	for p < len(g.items) && g.items[p].kind == globStar {
+		p++
+		set[p] = true
+	}
+	return set
+}
+
+// step computes the set for the label's last byte
+// and reports whether it is not empty.
+func (g *glob) step() bool {
+	i := len(g.label)
+	if len(g.sets) <= i {
+		g.sets = append(g.sets, make([]bool, len(g.items)+1))
+	}
+	prev, set := g.sets[i-1], g.sets[i]
+	c := g.label[i-1]
+	ok := false
+	for p := range set {
+		set[p] = false
+	}
+	for p, it := range g.items {
+		if !prev[p] {
+			continue
+		}
+		switch {
+		case it.kind == globStar:
+			if c != g.delim {
+				g.closure(set, p)
+				ok = true
+			}
+		case it.kind == globAny && c == g.delim:
+		case it.match(c):
+			g.closure(set, p+1)
+			ok = true
+		}
+	}
+	return ok
+}
+
+func (g *glob) walk(n *Node, depth int) {
+	size := len(g.label)
+	if n.Value != nil && g.sets[size][len(g.items)] {
+		g.entries = append(g.entries, Entry{Label: string(g.label), Node: n.at(depth)})
+	}
+	for e := n.edgeAfter(nil); e != nil; e = n.edgeAfter(e) {
+		ok := true
+		for i := 0; i < len(e.label) && ok; i++ {
+			g.label = append(g.label, e.label[i])
+			ok = g.step()
+		}
+		if ok {
+			g.walk(e.node, depth+1)
+		}
+		g.label = g.label[:size]
+	}
+}
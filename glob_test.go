@@ -0,0 +1,103 @@
+package radix_test
+
+import (
+	"path"
+	"regexp"
+	"testing"
+
+	. "github.com/knnat/radix"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatchGlob(t *testing.T) {
+	tr := New()
+	for _, l := range []string{"rubens", "ruben", "rubin", "rubicon", "romane", "user/1/posts", "user/12", "user/ab"} {
+		assert.Nil(t, tr.Add(l, l))
+	}
+	labels := func(entries []Entry) []string {
+		var labels []string
+		for _, e := range entries {
+			assert.Equal(t, e.Label, e.Node.Value)
+			labels = append(labels, e.Label)
+		}
+		return labels
+	}
+	for _, tc := range []struct {
+		pattern string
+		want    []string
+	}{
+		{"rub*n?", []string{"rubens"}},
+		{"rub*n", []string{"ruben", "rubicon", "rubin"}},
+		{"r[a-o]*", []string{"romane"}},
+		{"r[^u]*", []string{"romane"}},
+		{"*", []string{"romane", "ruben", "rubens", "rubicon", "rubin"}},
+		{"user/*", []string{"user/12", "user/ab"}},
+		{"user/[0-9]*/*", []string{"user/1/posts"}},
+		{"user/??", []string{"user/12", "user/ab"}},
+		{"user?12", nil},
+		{"rub\\en", []string{"ruben"}},
+		{"x*", nil},
+		{"rubx*", nil},
+	} {
+		entries, err := tr.MatchGlob(tc.pattern)
+		assert.Nil(t, err, tc.pattern)
+		assert.Equal(t, tc.want, labels(entries), tc.pattern)
+	}
+	for _, pattern := range []string{"[", "rub[a-", "rub[z-a]", "rub\\", "[]"} {
+		_, err := tr.MatchGlob(pattern)
+		assert.Equal(t, path.ErrBadPattern, err, pattern)
+	}
+}
+
+func TestMatchGlobBruteForce(t *testing.T) {
+	tr := New()
+	keys := benchKeys[:2000]
+	for _, k := range keys {
+		assert.Nil(t, tr.Set(k, k))
+	}
+	for _, pattern := range []string{"a*", "*a", "?b*c", "[a-f]*[0-9]?", "[^a-z]*/*", "*x*y*", "Q?"} {
+		var want []string
+		for _, k := range keys {
+			if ok, _ := path.Match(pattern, k); ok {
+				want = append(want, k)
+			}
+		}
+		entries, err := tr.MatchGlob(pattern)
+		assert.Nil(t, err)
+		got := make(map[string]bool)
+		for _, e := range entries {
+			got[e.Label] = true
+		}
+		assert.Len(t, got, len(want), pattern)
+		for _, k := range want {
+			assert.True(t, got[k], pattern)
+		}
+	}
+}
+
+func TestMatchRegexp(t *testing.T) {
+	tr := New()
+	for _, l := range []string{"user/1/posts", "user/12", "user/ab", "users", "admin/user/3"} {
+		assert.Nil(t, tr.Add(l, l))
+	}
+	labels := func(entries []Entry) []string {
+		var labels []string
+		for _, e := range entries {
+			labels = append(labels, e.Label)
+		}
+		return labels
+	}
+	for _, tc := range []struct {
+		re   string
+		want []string
+	}{
+		{`^user/[0-9]+/.*$`, []string{"user/1/posts"}},
+		{`^user/[0-9]+`, []string{"user/1/posts", "user/12"}},
+		{`user/[0-9]+$`, []string{"admin/user/3", "user/12"}},
+		{`^(?i)USER/AB$`, []string{"user/ab"}},
+		{`^users?$`, []string{"users"}},
+		{`^x`, nil},
+	} {
+		assert.Equal(t, tc.want, labels(tr.MatchRegexp(regexp.MustCompile(tc.re))), tc.re)
+	}
+}
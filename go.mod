@@ -1,8 +1,13 @@
 module github.com/knnat/radix
 
+go 1.19
+
 require (
 	github.com/gbrlsnchs/color v0.1.0
-	github.com/gbrlsnchs/radix v1.0.0
-	github.com/stretchr/objx v0.2.0 // indirect
 	github.com/stretchr/testify v1.3.0
 )
+
+require (
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+)
@@ -0,0 +1,424 @@
+package radix
+
+import (
+	"math/bits"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// IntTree is a tree of uint64 keys mapped to values,
+// which finds keys in order, by range and by their neighbours.
+//
+// Keys are split into their high 57 bits, which are the labels of a tree,
+// written big-endian 7 bits per byte with the high bit set, so that
+// labels never hold escape symbols nor delimiters, and their low 7 bits,
+// which index a leaf of up to 128 values. Dense ranges of keys then cost
+// little more than their values.
+//
+// Example:
+//
+//	1000 -> label "\x80\x80\x80\x80\x80\x80\x80\x80\x87", slot 104
+type IntTree struct {
+	leafTree
+	length atomic.Int64
+}
+
+// leafTree is a tree whose values are leaves of several values, such as
+// intLeaf, which are updated under its own lock rather than the tree's.
+type leafTree struct {
+	tr     *Tree
+	mu     *sync.RWMutex
+	safe   bool
+	atomic bool
+}
+
+// intLeaf holds the values of the keys sharing their high bits.
+type intLeaf struct {
+	bits   [2]uint64     // which of the 128 keys are set
+	values []interface{} // values of the keys set, in order
+}
+
+// NewIntTree creates an empty tree of uint64 keys,
+// only using the Tsafe and Tatomic flags of the settings.
+func (s *Settings) NewIntTree() *IntTree {
+	return &IntTree{leafTree: s.newLeafTree()}
+}
+
+// newLeafTree creates an empty tree of leaves,
+// only using the Tsafe and Tatomic flags of the settings.
+func (s *Settings) newLeafTree() leafTree {
+	var lt leafTree
+	flags := 0
+	if s.Flags&(Tsafe|Tatomic) > 0 {
+		lt.mu = &sync.RWMutex{}
+		lt.safe = true
+	}
+	if s.Flags&Tatomic > 0 {
+		lt.atomic = true
+		flags = Tatomic
+	}
+	lt.tr = (&Settings{Flags: flags, Escape: defaults.Escape, Delimiter: defaults.Delimiter}).New()
+	return lt
+}
+
+// NewIntTree creates an empty tree of uint64 keys with the default settings.
+func NewIntTree() *IntTree {
+	return defaults.NewIntTree()
+}
+
+// intLabelLen is the length of the labels of an IntTree.
+const intLabelLen = 9
+
+// intLabel returns the label of a key's high bits.
+func intLabel(k uint64) [intLabelLen]byte {
+	var b [intLabelLen]byte
+	k >>= 7
+	for i := intLabelLen - 1; i >= 0; i-- {
+		b[i] = 0x80 | byte(k&0x7f)
+		k >>= 7
+	}
+	return b
+}
+
+// intKey returns the key of a label's bits and a slot of its leaf.
+func intKey(label string, slot int) uint64 {
+	var k uint64
+	for i := 0; i < len(label); i++ {
+		k = k<<7 | uint64(label[i]&0x7f)
+	}
+	return k<<7 | uint64(slot)
+}
+
+func (l *intLeaf) has(slot int) bool {
+	return l.bits[slot/64]&(1<<(slot%64)) != 0
+}
+
+// rank returns the index of a slot's value.
+func (l *intLeaf) rank(slot int) int {
+	if slot < 64 {
+		return bits.OnesCount64(l.bits[0] & (1<<slot - 1))
+	}
+	return bits.OnesCount64(l.bits[0]) + bits.OnesCount64(l.bits[1]&(1<<(slot-64)-1))
+}
+
+// next returns the lowest slot set from slot on, or -1.
+func (l *intLeaf) next(slot int) int {
+	for ; slot < 128; slot = (slot/64 + 1) * 64 {
+		if w := l.bits[slot/64] >> (slot % 64); w != 0 {
+			return slot + bits.TrailingZeros64(w)
+		}
+	}
+	return -1
+}
+
+// prev returns the highest slot set up to slot, or -1.
+func (l *intLeaf) prev(slot int) int {
+	for ; slot >= 0; slot = slot/64*64 - 1 {
+		if w := l.bits[slot/64] << (63 - slot%64); w != 0 {
+			return slot - bits.LeadingZeros64(w)
+		}
+	}
+	return -1
+}
+
+func (l *intLeaf) copy() *intLeaf {
+	c := &intLeaf{bits: l.bits, values: make([]interface{}, len(l.values), len(l.values)+1)}
+	copy(c.values, l.values)
+	return c
+}
+
+func (lt *leafTree) rlock() {
+	if lt.safe && !lt.atomic {
+		lt.mu.RLock()
+	}
+}
+
+func (lt *leafTree) runlock() {
+	if lt.safe && !lt.atomic {
+		lt.mu.RUnlock()
+	}
+}
+
+func (lt *leafTree) lock() {
+	if lt.safe {
+		lt.mu.Lock()
+	}
+}
+
+func (lt *leafTree) unlock() {
+	if lt.safe {
+		lt.mu.Unlock()
+	}
+}
+
+// leaf returns the leaf of a label, if any.
+//
+// As labels hold no placeholders, edges are followed by their first byte
+// only, which, unlike Get, doesn't let the label escape to the heap.
+func (lt *leafTree) leaf(label []byte) *intLeaf {
+	defer lt.tr.runlock()
+	n := lt.tr.rlock().root
+	for len(label) > 0 {
+		e := n.child(label[0])
+		if e == nil || len(e.label) > len(label) || string(label[:len(e.label)]) != e.label {
+			return nil
+		}
+		n, label = e.node, label[len(e.label):]
+	}
+	if n.Value == nil {
+		return nil
+	}
+	return n.Value.(*intLeaf)
+}
+
+// Insert adds a key to the tree or, if it is already there, replaces its value.
+// Nil values aren't added.
+func (it *IntTree) Insert(k uint64, v interface{}) {
+	if v == nil {
+		return
+	}
+	defer it.unlock()
+	it.lock()
+	label := intLabel(k)
+	if it.insert(label[:], int(k&0x7f), v) {
+		it.length.Add(1)
+	}
+}
+
+// insert sets the value of a slot of a label's leaf,
+// and reports whether the slot was empty.
+func (lt *leafTree) insert(label []byte, slot int, v interface{}) bool {
+	l := lt.leaf(label)
+	fresh := l == nil
+	if fresh {
+		l = &intLeaf{}
+	} else if lt.atomic {
+		// Readers may be reading the published leaf.
+		l = l.copy()
+	}
+	i := l.rank(slot)
+	added := !l.has(slot)
+	if added {
+		l.bits[slot/64] |= 1 << (slot % 64)
+		l.values = append(l.values, nil)
+		copy(l.values[i+1:], l.values[i:])
+	}
+	l.values[i] = v
+	if fresh || lt.atomic {
+		lt.tr.Set(string(label), l)
+	}
+	return added
+}
+
+// Delete deletes a key.
+func (it *IntTree) Delete(k uint64) {
+	defer it.unlock()
+	it.lock()
+	label := intLabel(k)
+	if it.delete(label[:], int(k&0x7f)) {
+		it.length.Add(-1)
+	}
+}
+
+// delete empties a slot of a label's leaf, deleting the label along with
+// its last value, and reports whether the slot was set.
+func (lt *leafTree) delete(label []byte, slot int) bool {
+	l := lt.leaf(label)
+	if l == nil || !l.has(slot) {
+		return false
+	}
+	if len(l.values) == 1 {
+		lt.tr.Del(unsafeString(label))
+		return true
+	}
+	if lt.atomic {
+		l = l.copy()
+	}
+	i := l.rank(slot)
+	l.bits[slot/64] &^= 1 << (slot % 64)
+	copy(l.values[i:], l.values[i+1:])
+	// Let the deleted value be collected.
+	l.values[len(l.values)-1] = nil
+	l.values = l.values[:len(l.values)-1]
+	if lt.atomic {
+		lt.tr.Set(string(label), l)
+	}
+	return true
+}
+
+// Get returns the value of a key.
+func (it *IntTree) Get(k uint64) (interface{}, bool) {
+	defer it.runlock()
+	it.rlock()
+	label := intLabel(k)
+	slot := int(k & 0x7f)
+	l := it.leaf(label[:])
+	if l == nil || !l.has(slot) {
+		return nil, false
+	}
+	return l.values[l.rank(slot)], true
+}
+
+// Len returns the number of keys in the tree.
+func (it *IntTree) Len() int {
+	return int(it.length.Load())
+}
+
+// Walk visits the keys of the tree in ascending order until fn returns false.
+//
+// The tree must not be modified by fn.
+func (it *IntTree) Walk(fn func(k uint64, v interface{}) bool) {
+	it.Range(0, 1<<64-1, fn)
+}
+
+// Range visits the keys from lo to hi, both included,
+// in ascending order until fn returns false.
+//
+// The tree must not be modified by fn.
+func (it *IntTree) Range(lo, hi uint64, fn func(k uint64, v interface{}) bool) {
+	if lo > hi {
+		return
+	}
+	defer it.runlock()
+	it.rlock()
+	start := intLabel(lo)
+	it.tr.walkFrom(unsafeString(start[:]), func(label string, n *Node) bool {
+		l := n.Value.(*intLeaf)
+		slot := 0
+		if label == unsafeString(start[:]) {
+			slot = int(lo & 0x7f)
+		}
+		for i := l.next(slot); i >= 0; i = l.next(i + 1) {
+			k := intKey(label, i)
+			if k > hi || !fn(k, l.values[l.rank(i)]) {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+// Floor returns the greatest key of the tree lower
+// than or equal to a key, along with its value.
+func (it *IntTree) Floor(k uint64) (uint64, interface{}, bool) {
+	defer it.runlock()
+	it.rlock()
+	label := intLabel(k)
+	if l := it.leaf(label[:]); l != nil {
+		if i := l.prev(int(k & 0x7f)); i >= 0 {
+			return k&^0x7f | uint64(i), l.values[l.rank(i)], true
+		}
+	}
+	prev, n := it.tr.before(string(label[:]))
+	if n == nil {
+		return 0, nil, false
+	}
+	l := n.Value.(*intLeaf)
+	i := l.prev(127)
+	return intKey(unsafeString(prev), i), l.values[l.rank(i)], true
+}
+
+// Ceiling returns the lowest key of the tree greater
+// than or equal to a key, along with its value.
+func (it *IntTree) Ceiling(k uint64) (uint64, interface{}, bool) {
+	var (
+		key   uint64
+		value interface{}
+		found bool
+	)
+	it.Range(k, 1<<64-1, func(k uint64, v interface{}) bool {
+		key, value, found = k, v, true
+		return false
+	})
+	return key, value, found
+}
+
+// walkFrom calls fn for the tree's labels that aren't lower than
+// start, in ascending order, until fn returns false.
+func (tr *Tree) walkFrom(start string, fn func(label string, n *Node) bool) {
+	defer tr.runlock()
+	tr.rlock().root.walkFrom(nil, 0, start, fn)
+}
+
+// walkFrom calls fn for the node, at the given depth, and its children in
+// ascending order of their labels, from the first one that isn't lower than
+// label followed by key, until fn returns false.
+func (n *Node) walkFrom(label []byte, depth int, key string, fn func(label string, n *Node) bool) bool {
+	if key == "" {
+		return n.walk(label, depth, fn)
+	}
+	for e := n.edgeAfter(nil); e != nil; e = n.edgeAfter(e) {
+		m := len(e.label)
+		if len(key) < m {
+			m = len(key)
+		}
+		switch c := strings.Compare(e.label[:m], key[:m]); {
+		case c < 0:
+			continue
+		case c > 0 || len(e.label) >= len(key):
+			if !e.node.walk(append(label, e.label...), depth+1, fn) {
+				return false
+			}
+		default:
+			if !e.node.walkFrom(append(label, e.label...), depth+1, key[m:], fn) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// before returns the greatest label of the tree lower than key, and its node.
+func (tr *Tree) before(key string) ([]byte, *Node) {
+	defer tr.runlock()
+	return tr.rlock().root.before(nil, key)
+}
+
+// before returns the greatest label of the node's subtree
+// lower than label followed by key, and its node.
+func (n *Node) before(label []byte, key string) ([]byte, *Node) {
+	var lower, prefix *edge // the last edge lower than key and the one it starts with
+	for e := n.edgeAfter(nil); e != nil; e = n.edgeAfter(e) {
+		m := len(e.label)
+		if len(key) < m {
+			m = len(key)
+		}
+		c := strings.Compare(e.label[:m], key[:m])
+		if c > 0 || c == 0 && len(e.label) >= len(key) {
+			break
+		}
+		if c < 0 {
+			lower = e
+		} else {
+			prefix = e
+		}
+	}
+	if prefix != nil {
+		if l, tnode := prefix.node.before(append(label, prefix.label...), key[len(prefix.label):]); tnode != nil {
+			return l, tnode
+		}
+	}
+	if lower != nil {
+		return lower.node.last(append(label, lower.label...))
+	}
+	if n.Value != nil {
+		return label, n
+	}
+	return nil, nil
+}
+
+// last returns the greatest label of the node's subtree, and its node.
+func (n *Node) last(label []byte) ([]byte, *Node) {
+	var last *edge
+	for e := n.edgeAfter(nil); e != nil; e = n.edgeAfter(e) {
+		last = e
+	}
+	if last == nil {
+		if n.Value == nil {
+			return nil, nil
+		}
+		return label, n
+	}
+	return last.node.last(append(label, last.label...))
+}
@@ -0,0 +1,150 @@
+package radix_test
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+
+	. "github.com/knnat/radix"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIntTree(t *testing.T) {
+	for _, flags := range []int{0, Tsafe, Tatomic} {
+		it := (&Settings{Flags: flags}).NewIntTree()
+		keys := []uint64{0, 1, 63, 64, 127, 128, 1000, 1 << 40, math.MaxUint64 - 1, math.MaxUint64}
+		for _, k := range keys {
+			it.Insert(k, k)
+		}
+		it.Insert(1000, "thousand")
+		it.Insert(5, nil)
+		assert.Equal(t, len(keys), it.Len())
+
+		v, ok := it.Get(1000)
+		assert.True(t, ok)
+		assert.Equal(t, "thousand", v)
+		_, ok = it.Get(5)
+		assert.False(t, ok)
+
+		var walked []uint64
+		it.Walk(func(k uint64, v interface{}) bool {
+			walked = append(walked, k)
+			return true
+		})
+		assert.Equal(t, keys, walked)
+
+		walked = nil
+		it.Range(2, 1000, func(k uint64, v interface{}) bool {
+			walked = append(walked, k)
+			return true
+		})
+		assert.Equal(t, []uint64{63, 64, 127, 128, 1000}, walked)
+		walked = nil
+		it.Range(64, math.MaxUint64, func(k uint64, v interface{}) bool {
+			walked = append(walked, k)
+			return len(walked) < 2
+		})
+		assert.Equal(t, []uint64{64, 127}, walked)
+
+		for _, tc := range []struct {
+			k, floor, ceiling uint64
+		}{
+			{0, 0, 0},
+			{2, 1, 63},
+			{129, 128, 1000},
+			{1 << 39, 1000, 1 << 40},
+			{1<<40 + 1, 1 << 40, math.MaxUint64 - 1},
+		} {
+			k, _, ok := it.Floor(tc.k)
+			assert.True(t, ok)
+			assert.Equal(t, tc.floor, k, "floor of %d", tc.k)
+			k, _, ok = it.Ceiling(tc.k)
+			assert.True(t, ok)
+			assert.Equal(t, tc.ceiling, k, "ceiling of %d", tc.k)
+		}
+
+		for _, k := range []uint64{0, 1, 128, math.MaxUint64} {
+			it.Delete(k)
+		}
+		it.Delete(5)
+		assert.Equal(t, len(keys)-4, it.Len())
+		_, _, ok = it.Floor(62)
+		assert.False(t, ok)
+		_, _, ok = it.Ceiling(math.MaxUint64)
+		assert.False(t, ok)
+		k, v, ok := it.Floor(1 << 39)
+		assert.True(t, ok)
+		assert.Equal(t, uint64(1000), k)
+		assert.Equal(t, "thousand", v)
+		k, _, ok = it.Ceiling(129)
+		assert.True(t, ok)
+		assert.Equal(t, uint64(1000), k)
+	}
+}
+
+func TestIntTreeRandom(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	it := NewIntTree()
+	m := make(map[uint64]int)
+	for i := 0; i < 5000; i++ {
+		// Both dense and sparse keys.
+		k := uint64(rnd.Intn(2000))
+		if i%2 == 0 {
+			k = rnd.Uint64()
+		}
+		if rnd.Intn(4) == 0 {
+			it.Delete(k)
+			delete(m, k)
+			continue
+		}
+		it.Insert(k, i)
+		m[k] = i
+	}
+	var sorted []uint64
+	for k := range m {
+		sorted = append(sorted, k)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	assert.Equal(t, len(m), it.Len())
+
+	var walked []uint64
+	it.Walk(func(k uint64, v interface{}) bool {
+		assert.Equal(t, m[k], v)
+		walked = append(walked, k)
+		return true
+	})
+	assert.Equal(t, sorted, walked)
+
+	for i := 0; i < 1000; i++ {
+		k := uint64(rnd.Intn(2100))
+		if i%2 == 0 {
+			k = rnd.Uint64()
+		}
+		j := sort.Search(len(sorted), func(i int) bool { return sorted[i] >= k })
+		c, _, ok := it.Ceiling(k)
+		assert.Equal(t, j < len(sorted), ok)
+		if ok {
+			assert.Equal(t, sorted[j], c)
+		}
+		j = sort.Search(len(sorted), func(i int) bool { return sorted[i] > k }) - 1
+		f, _, ok := it.Floor(k)
+		assert.Equal(t, j >= 0, ok)
+		if ok {
+			assert.Equal(t, sorted[j], f)
+		}
+	}
+}
+
+func TestIntTreeAllocs(t *testing.T) {
+	it := NewIntTree()
+	for i := uint64(0); i < 1000; i++ {
+		it.Insert(i*3, i)
+	}
+	allocs := testing.AllocsPerRun(100, func() {
+		it.Get(300)
+		it.Get(301)
+		it.Floor(301)
+	})
+	assert.Equal(t, 0.0, allocs)
+}
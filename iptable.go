@@ -0,0 +1,353 @@
+package radix
+
+import (
+	"errors"
+	"math/bits"
+	"net/netip"
+	"strings"
+)
+
+// ErrPrefix indicates an invalid IP prefix.
+var ErrPrefix = errors.New("invalid IP prefix")
+
+// PrefixTable is a table of IPv4 and IPv6 prefixes mapped to values,
+// which finds the longest prefix containing an address.
+//
+// Prefixes are split, after a byte telling their family apart, into their
+// whole groups of 7 bits, which are the labels of a tree, written a group
+// per byte with the high bit set, like the keys of an IntTree, and the 0 to
+// 6 bits left, which index a leaf of up to 127 values, one per length and
+// value of those bits. Both families share the same table, and an IPv4
+// prefix never contains an IPv6 address, nor the other way round,
+// including IPv4-mapped IPv6 ones.
+//
+// An IPv6 prefix then costs at most 19 bytes of label, and a lookup walks
+// down the tree a group at a time, masking the bits left of the address's
+// next group for each leaf on the way.
+//
+// Example:
+//
+//	10.0.0.0/8 -> label "4\x85", slot 0b10 (1 bit left, 0)
+//	10.1.0.0/16 -> label "4\x85\x80", slot 0b101 (2 bits left, 0b01)
+type PrefixTable struct {
+	leafTree
+}
+
+// PrefixEntry is a prefix of a table, along with its value.
+type PrefixEntry struct {
+	Prefix netip.Prefix
+	Value  interface{}
+}
+
+// NewPrefixTable creates an empty table of prefixes,
+// only using the Tsafe and Tatomic flags of the settings.
+func (s *Settings) NewPrefixTable() *PrefixTable {
+	return &PrefixTable{leafTree: s.newLeafTree()}
+}
+
+// NewPrefixTable creates an empty table of prefixes with the default settings.
+func NewPrefixTable() *PrefixTable {
+	return defaults.NewPrefixTable()
+}
+
+// Insert adds a prefix to the table or, if it is
+// already there, replaces its value. Nil values aren't added.
+//
+// The prefix's bits past its length are ignored,
+// so 10.1.2.3/8 is the same prefix as 10.0.0.0/8.
+func (t *PrefixTable) Insert(p netip.Prefix, v interface{}) error {
+	if !p.IsValid() {
+		return ErrPrefix
+	}
+	if v == nil {
+		return nil
+	}
+	defer t.unlock()
+	t.lock()
+	var buf [prefixLabelLen]byte
+	label, slot := prefixLabel(buf[:0], p.Addr(), p.Bits())
+	t.insert(label, slot, v)
+	return nil
+}
+
+// Delete deletes a prefix from the table.
+func (t *PrefixTable) Delete(p netip.Prefix) {
+	if !p.IsValid() {
+		return
+	}
+	defer t.unlock()
+	t.lock()
+	var buf [prefixLabelLen]byte
+	label, slot := prefixLabel(buf[:0], p.Addr(), p.Bits())
+	t.delete(label, slot)
+}
+
+// Get returns the value of a prefix of the table.
+func (t *PrefixTable) Get(p netip.Prefix) (interface{}, bool) {
+	if !p.IsValid() {
+		return nil, false
+	}
+	defer t.runlock()
+	t.rlock()
+	var buf [prefixLabelLen]byte
+	label, slot := prefixLabel(buf[:0], p.Addr(), p.Bits())
+	l := t.leaf(label)
+	if l == nil || !l.has(slot) {
+		return nil, false
+	}
+	return l.values[l.rank(slot)], true
+}
+
+// Lookup returns the longest prefix of the table
+// containing an address, along with its value.
+func (t *PrefixTable) Lookup(a netip.Addr) (netip.Prefix, interface{}, bool) {
+	if !a.IsValid() {
+		return netip.Prefix{}, nil, false
+	}
+	defer t.runlock()
+	t.rlock()
+	var (
+		buf   [prefixLabelLen]byte
+		value interface{}
+		best  = -1
+	)
+	// The label holds the address's groups, the last one padded with zeros.
+	n := a.BitLen()
+	label, _ := prefixLabel(buf[:0], a, n+7)
+	t.leaves(label[:1+n/7], func(i int, l *intLeaf) bool {
+		for k := 6; k >= 0; k-- {
+			if bits := 7*(i-1) + k; bits <= n {
+				if slot := prefixSlot(k, label[i]); l.has(slot) {
+					value, best = l.values[l.rank(slot)], bits
+					break
+				}
+			}
+		}
+		return true
+	})
+	if best < 0 {
+		return netip.Prefix{}, nil, false
+	}
+	p, _ := a.WithZone("").Prefix(best)
+	return p, value, true
+}
+
+// Contains reports whether any prefix of the table contains an address.
+func (t *PrefixTable) Contains(a netip.Addr) bool {
+	_, _, ok := t.Lookup(a)
+	return ok
+}
+
+// Covering returns the prefixes of the table which contain a prefix,
+// including the prefix itself, from the shortest to the longest.
+func (t *PrefixTable) Covering(p netip.Prefix) []PrefixEntry {
+	if !p.IsValid() {
+		return nil
+	}
+	defer t.runlock()
+	t.rlock()
+	var (
+		entries []PrefixEntry
+		buf     [prefixLabelLen]byte
+	)
+	a, n := p.Addr().WithZone(""), p.Bits()
+	label, _ := prefixLabel(buf[:0], a, n+7)
+	t.leaves(label[:1+n/7], func(i int, l *intLeaf) bool {
+		for k := 0; k < 7 && 7*(i-1)+k <= n; k++ {
+			if slot := prefixSlot(k, label[i]); l.has(slot) {
+				q, _ := a.Prefix(7*(i-1) + k)
+				entries = append(entries, PrefixEntry{q, l.values[l.rank(slot)]})
+			}
+		}
+		return true
+	})
+	return entries
+}
+
+// Covered returns the prefixes of the table which a prefix contains,
+// including the prefix itself, ordered by address and then by length.
+func (t *PrefixTable) Covered(p netip.Prefix) []PrefixEntry {
+	if !p.IsValid() {
+		return nil
+	}
+	defer t.runlock()
+	t.rlock()
+	p = p.Masked()
+	var (
+		entries []PrefixEntry
+		buf     [prefixLabelLen]byte
+	)
+	label, _ := prefixLabel(buf[:0], p.Addr(), p.Bits())
+	// Labels starting with the prefix's groups may hold prefixes
+	// whose bits left don't match the prefix's.
+	t.walk(string(label), func(q netip.Prefix, v interface{}) bool {
+		if q.Bits() >= p.Bits() && p.Contains(q.Addr()) {
+			entries = append(entries, PrefixEntry{q, v})
+		}
+		return true
+	})
+	return entries
+}
+
+// Walk visits the prefixes of the table, IPv4 ones first, ordered
+// by address and then by length, until fn returns false.
+//
+// The table must not be modified by fn.
+func (t *PrefixTable) Walk(fn func(p netip.Prefix, v interface{}) bool) {
+	defer t.runlock()
+	t.rlock()
+	t.walk("", fn)
+}
+
+// walk calls fn for the prefixes of the labels starting with prefix,
+// ordered by address and then by length, until fn returns false.
+//
+// A leaf's prefixes are visited along with the labels below it, as its
+// prefixes whose address is up to the first group after its label come
+// before the labels starting with that group.
+func (t *PrefixTable) walk(prefix string, fn func(p netip.Prefix, v interface{}) bool) {
+	type pending struct {
+		label string
+		leaf  *intLeaf
+		next  int // index in prefixSlots of the next slot to visit
+	}
+	var stack []pending
+	// flush visits the slots of a pending leaf whose prefixes'
+	// addresses have a group up to max after its label.
+	flush := func(p *pending, max byte) bool {
+		for ; p.next < len(prefixSlots); p.next++ {
+			slot := int(prefixSlots[p.next])
+			if slotGroup(slot) > max {
+				return true
+			}
+			if p.leaf.has(slot) && !fn(labelPrefix(p.label, slot), p.leaf.values[p.leaf.rank(slot)]) {
+				return false
+			}
+		}
+		return true
+	}
+	ok := true
+	t.tr.WalkPrefix(prefix, func(label string, n *Node) bool {
+		for len(stack) > 0 && !strings.HasPrefix(label, stack[len(stack)-1].label) {
+			if ok = flush(&stack[len(stack)-1], 0x7f); !ok {
+				return false
+			}
+			stack = stack[:len(stack)-1]
+		}
+		for i := range stack {
+			if ok = flush(&stack[i], label[len(stack[i].label)]&0x7f); !ok {
+				return false
+			}
+		}
+		stack = append(stack, pending{label: label, leaf: n.Value.(*intLeaf)})
+		return true
+	})
+	for i := len(stack) - 1; i >= 0 && ok; i-- {
+		ok = flush(&stack[i], 0x7f)
+	}
+}
+
+// leaves calls fn for the leaves of the labels that label starts with,
+// from the shortest one, along with their lengths, until fn returns false.
+func (lt *leafTree) leaves(label []byte, fn func(i int, l *intLeaf) bool) {
+	defer lt.tr.runlock()
+	n := lt.tr.rlock().root
+	for i := 0; ; {
+		if n.Value != nil && !fn(i, n.Value.(*intLeaf)) || i == len(label) {
+			return
+		}
+		e := n.child(label[i])
+		if e == nil || len(e.label) > len(label)-i || string(label[i:i+len(e.label)]) != e.label {
+			return
+		}
+		n, i = e.node, i+len(e.label)
+	}
+}
+
+// prefixLabelLen is the length of the longest label of a prefix,
+// along with the group of the bits left.
+const prefixLabelLen = 1 + 128/7 + 1
+
+// prefixLabel appends to b the label of an address's first n bits, which is
+// its family, '4' or '6', followed by its whole groups of 7 bits, and returns
+// it along with the slot of the bits left in the label's leaf.
+func prefixLabel(b []byte, a netip.Addr, n int) ([]byte, int) {
+	var ip [16]byte
+	if a.Is4() {
+		b = append(b, '4')
+		a4 := a.As4()
+		copy(ip[:], a4[:])
+	} else {
+		b = append(b, '6')
+		ip = a.As16()
+	}
+	for g := 0; g < n/7; g++ {
+		b = append(b, 0x80|prefixGroup(&ip, g))
+	}
+	return b, prefixSlot(n%7, prefixGroup(&ip, n/7))
+}
+
+// prefixGroup returns the 7 bits of an address from bit 7g on,
+// padded with zeros past the address.
+func prefixGroup(ip *[16]byte, g int) byte {
+	i := 7 * g
+	if i/8 >= len(ip) {
+		return 0
+	}
+	w := uint16(ip[i/8]) << 8
+	if i/8+1 < len(ip) {
+		w |= uint16(ip[i/8+1])
+	}
+	return byte(w>>(9-i%8)) & 0x7f
+}
+
+// setPrefixGroup sets the 7 bits of an address from bit 7g on.
+func setPrefixGroup(ip *[16]byte, g int, c byte) {
+	i := 7 * g
+	w := uint16(c&0x7f) << (9 - i%8)
+	ip[i/8] |= byte(w >> 8)
+	if i/8+1 < len(ip) {
+		ip[i/8+1] |= byte(w)
+	}
+}
+
+// prefixSlot returns the slot of the first k bits of a group.
+func prefixSlot(k int, group byte) int {
+	return 1<<k | int(group&0x7f)>>(7-k)
+}
+
+// slotGroup returns the lowest group of the addresses of a slot's prefix.
+func slotGroup(slot int) byte {
+	k := bits.Len(uint(slot)) - 1
+	return byte(slot&^(1<<k)) << (7 - k)
+}
+
+// prefixSlots lists the slots of a leaf, ordered by
+// the address and then by the length of their prefixes.
+var prefixSlots = func() (slots [127]uint8) {
+	i := 0
+	for g := 0; g < 128; g++ {
+		for k := 0; k < 7; k++ {
+			if g&(0x7f>>k) == 0 {
+				slots[i] = uint8(prefixSlot(k, byte(g)))
+				i++
+			}
+		}
+	}
+	return slots
+}()
+
+// labelPrefix returns the prefix of a label built
+// by prefixLabel and of a slot of its leaf.
+func labelPrefix(label string, slot int) netip.Prefix {
+	var ip [16]byte
+	for g := 1; g < len(label); g++ {
+		setPrefixGroup(&ip, g-1, label[g])
+	}
+	setPrefixGroup(&ip, len(label)-1, slotGroup(slot))
+	n := 7*(len(label)-1) + bits.Len(uint(slot)) - 1
+	if label[0] == '4' {
+		return netip.PrefixFrom(netip.AddrFrom4([4]byte{ip[0], ip[1], ip[2], ip[3]}), n)
+	}
+	return netip.PrefixFrom(netip.AddrFrom16(ip), n)
+}
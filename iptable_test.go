@@ -0,0 +1,193 @@
+package radix_test
+
+import (
+	"math/rand"
+	"net/netip"
+	"sort"
+	"testing"
+
+	. "github.com/knnat/radix"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrefixTable(t *testing.T) {
+	for _, flags := range []int{0, Tsafe, Tatomic} {
+		tab := (&Settings{Flags: flags}).NewPrefixTable()
+		for _, p := range []string{
+			"0.0.0.0/0",
+			"10.0.0.0/8",
+			"10.1.2.3/16",
+			"10.1.2.0/24",
+			"192.168.0.0/16",
+			"2001:db8::/32",
+			"2001:db8:1::/48",
+			"::/0",
+		} {
+			assert.Nil(t, tab.Insert(netip.MustParsePrefix(p), p))
+		}
+		assert.Equal(t, ErrPrefix, tab.Insert(netip.Prefix{}, "x"))
+		assert.Nil(t, tab.Insert(netip.MustParsePrefix("10.0.0.0/8"), "ten"))
+
+		lookup := func(addr string) string {
+			p, v, ok := tab.Lookup(netip.MustParseAddr(addr))
+			if !ok {
+				return ""
+			}
+			return p.String() + " " + v.(string)
+		}
+		assert.Equal(t, "10.1.2.0/24 10.1.2.0/24", lookup("10.1.2.200"))
+		assert.Equal(t, "10.1.0.0/16 10.1.2.3/16", lookup("10.1.3.1"))
+		assert.Equal(t, "10.0.0.0/8 ten", lookup("10.2.0.1"))
+		assert.Equal(t, "0.0.0.0/0 0.0.0.0/0", lookup("8.8.8.8"))
+		assert.Equal(t, "2001:db8:1::/48 2001:db8:1::/48", lookup("2001:db8:1::1"))
+		assert.Equal(t, "2001:db8::/32 2001:db8::/32", lookup("2001:db8:2::1"))
+		assert.Equal(t, "::/0 ::/0", lookup("::ffff:10.1.2.3"))
+
+		v, ok := tab.Get(netip.MustParsePrefix("10.1.0.0/16"))
+		assert.True(t, ok)
+		assert.Equal(t, "10.1.2.3/16", v)
+		_, ok = tab.Get(netip.MustParsePrefix("10.1.0.0/17"))
+		assert.False(t, ok)
+
+		prefixes := func(entries []PrefixEntry) []string {
+			var ps []string
+			for _, e := range entries {
+				ps = append(ps, e.Prefix.String())
+			}
+			return ps
+		}
+		assert.Equal(t, []string{"0.0.0.0/0", "10.0.0.0/8", "10.1.0.0/16"},
+			prefixes(tab.Covering(netip.MustParsePrefix("10.1.128.0/17"))))
+		assert.Equal(t, []string{"10.0.0.0/8", "10.1.0.0/16", "10.1.2.0/24"},
+			prefixes(tab.Covered(netip.MustParsePrefix("10.0.0.0/8"))))
+		assert.Equal(t, []string{"2001:db8::/32", "2001:db8:1::/48"},
+			prefixes(tab.Covered(netip.MustParsePrefix("2001:db8::/31"))))
+		assert.Nil(t, tab.Covered(netip.MustParsePrefix("172.16.0.0/12")))
+
+		tab.Delete(netip.MustParsePrefix("0.0.0.0/0"))
+		tab.Delete(netip.MustParsePrefix("10.1.0.0/16"))
+		assert.False(t, tab.Contains(netip.MustParseAddr("8.8.8.8")))
+		assert.True(t, tab.Contains(netip.MustParseAddr("8:8::")))
+		assert.Equal(t, "10.0.0.0/8 ten", lookup("10.1.3.1"))
+
+		var walked []string
+		tab.Walk(func(p netip.Prefix, v interface{}) bool {
+			walked = append(walked, p.String())
+			return true
+		})
+		assert.Equal(t, []string{
+			"10.0.0.0/8", "10.1.2.0/24", "192.168.0.0/16",
+			"::/0", "2001:db8::/32", "2001:db8:1::/48",
+		}, walked)
+	}
+}
+
+func TestPrefixTableUnaligned(t *testing.T) {
+	tab := NewPrefixTable()
+	for _, p := range []string{"172.16.0.0/12", "172.16.0.0/13", "172.24.0.0/13", "172.20.0.0/14", "172.16.16.0/20", "2001:db8::/33"} {
+		assert.Nil(t, tab.Insert(netip.MustParsePrefix(p), p))
+	}
+	lookup := func(addr string) string {
+		p, _, _ := tab.Lookup(netip.MustParseAddr(addr))
+		return p.String()
+	}
+	assert.Equal(t, "172.16.16.0/20", lookup("172.16.31.1"))
+	assert.Equal(t, "172.16.0.0/13", lookup("172.16.32.1"))
+	assert.Equal(t, "172.20.0.0/14", lookup("172.21.0.1"))
+	assert.Equal(t, "172.24.0.0/13", lookup("172.31.255.255"))
+	assert.Equal(t, "invalid Prefix", lookup("172.32.0.0"))
+	assert.Equal(t, "2001:db8::/33", lookup("2001:db8:7fff::"))
+	assert.Equal(t, "invalid Prefix", lookup("2001:db8:8000::"))
+
+	_, ok := tab.Get(netip.MustParsePrefix("172.16.0.0/14"))
+	assert.False(t, ok)
+	v, ok := tab.Get(netip.MustParsePrefix("172.23.0.0/13"))
+	assert.True(t, ok)
+	assert.Equal(t, "172.16.0.0/13", v)
+}
+
+func TestPrefixTableRandom(t *testing.T) {
+	for _, flags := range []int{0, Tatomic} {
+		rnd := rand.New(rand.NewSource(1))
+		tab := (&Settings{Flags: flags}).NewPrefixTable()
+		m := make(map[netip.Prefix]int)
+		// Few addresses, so that their prefixes nest.
+		var addrs []netip.Addr
+		for i := 0; i < 8; i++ {
+			var ip [16]byte
+			rnd.Read(ip[:])
+			a := netip.AddrFrom16(ip)
+			if i%2 == 0 {
+				a = netip.AddrFrom4([4]byte{ip[0], ip[1], ip[2], ip[3]})
+			}
+			addrs = append(addrs, a)
+		}
+		random := func() netip.Prefix {
+			a := addrs[rnd.Intn(len(addrs))]
+			p, _ := a.Prefix(rnd.Intn(a.BitLen() + 1))
+			return p
+		}
+		for i := 0; i < 2000; i++ {
+			p := random()
+			if rnd.Intn(4) == 0 {
+				tab.Delete(p)
+				delete(m, p)
+				continue
+			}
+			tab.Insert(p, i)
+			m[p] = i
+		}
+
+		var sorted []netip.Prefix
+		for p := range m {
+			sorted = append(sorted, p)
+		}
+		sort.Slice(sorted, func(i, j int) bool {
+			if c := sorted[i].Addr().Compare(sorted[j].Addr()); c != 0 {
+				return c < 0
+			}
+			return sorted[i].Bits() < sorted[j].Bits()
+		})
+		var walked []netip.Prefix
+		tab.Walk(func(p netip.Prefix, v interface{}) bool {
+			assert.Equal(t, m[p], v)
+			walked = append(walked, p)
+			return true
+		})
+		assert.Equal(t, sorted, walked)
+
+		for i := 0; i < 500; i++ {
+			p := random()
+			var covering, covered []netip.Prefix
+			for _, q := range sorted {
+				if q.Bits() <= p.Bits() && q.Contains(p.Addr()) {
+					covering = append(covering, q)
+				}
+				if q.Bits() >= p.Bits() && p.Contains(q.Addr()) {
+					covered = append(covered, q)
+				}
+			}
+			sort.Slice(covering, func(i, j int) bool { return covering[i].Bits() < covering[j].Bits() })
+			var got []netip.Prefix
+			for _, e := range tab.Covering(p) {
+				got = append(got, e.Prefix)
+			}
+			assert.Equal(t, covering, got)
+			got = nil
+			for _, e := range tab.Covered(p) {
+				got = append(got, e.Prefix)
+			}
+			assert.Equal(t, covered, got)
+
+			q, _, ok := tab.Lookup(p.Addr())
+			want := netip.Prefix{}
+			for _, c := range sorted {
+				if c.Contains(p.Addr()) && (!want.IsValid() || c.Bits() > want.Bits()) {
+					want = c
+				}
+			}
+			assert.Equal(t, want.IsValid(), ok)
+			assert.Equal(t, want, q)
+		}
+	}
+}
@@ -0,0 +1,313 @@
+// Package keyenc encodes tuples of values into keys whose byte order is the
+// order of the tuples, so that trees keyed by them walk tuples in order and
+// a tuple's prefix is a prefix of its key.
+//
+// Tuples are ordered element by element, a tuple sorting before those it
+// is a prefix of. Elements of different types are ordered by type, in the
+// order strings, signed integers, unsigned integers, floats and times.
+//
+// Keys never hold the byte they are encoded for, which is meant to be
+// the escape symbol of the tree they are added to, so that they are
+// always matched literally.
+package keyenc
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/knnat/radix"
+)
+
+var (
+	// ErrType indicates a value of a type that can't be encoded.
+	ErrType = errors.New("keyenc: unsupported type")
+
+	// ErrKey indicates a malformed key.
+	ErrKey = errors.New("keyenc: malformed key")
+)
+
+// Type tags, which order elements of different types.
+const (
+	tagString byte = 0x02 + iota
+	tagInt
+	tagUint
+	tagFloat
+	tagTime
+)
+
+// Encoder encodes tuples into keys without a given byte.
+//
+// Before being stripped of that byte, elements are encoded as a type tag
+// followed by:
+//
+//	string: its bytes, 0x00 escaped as 0x00 0xff, up to 0x00 0x01
+//	int64: 8 bytes, big-endian, with the sign bit flipped
+//	uint64: 8 bytes, big-endian
+//	float64: its 8 bytes, big-endian, with the sign bit flipped when
+//	positive and all bits flipped when negative
+//	time.Time: its Unix seconds as an int64 and its nanoseconds as 4 bytes
+//
+// Then, with p the byte next to the reserved one, either byte is replaced
+// by p and a marker telling them apart, so that their order is kept.
+type Encoder struct {
+	reserved byte
+	p        byte // replaces both p and reserved
+	lo, hi   byte // markers following p, for the lowest of p and reserved and the other
+}
+
+// New returns an encoder whose keys never hold the reserved byte,
+// such as the escape symbol of the tree they are added to.
+func New(reserved byte) *Encoder {
+	e := &Encoder{reserved: reserved, p: reserved - 1}
+	if reserved == 0 {
+		e.p = 1
+	}
+	var markers []byte
+	for b := byte(0); len(markers) < 2; b++ {
+		if b != reserved {
+			markers = append(markers, b)
+		}
+	}
+	e.lo, e.hi = markers[0], markers[1]
+	return e
+}
+
+// Encode encodes a tuple into a key.
+//
+// Values are strings, byte slices, signed and unsigned integers, floats
+// and times, which are decoded as strings, int64, uint64, float64 and
+// time.Time values in UTC respectively.
+func (e *Encoder) Encode(values ...interface{}) (string, error) {
+	var b []byte
+	for _, v := range values {
+		var err error
+		if b, err = appendValue(b, v); err != nil {
+			return "", err
+		}
+	}
+	return e.strip(b), nil
+}
+
+// MustEncode is like Encode but panics if a value can't be encoded.
+func (e *Encoder) MustEncode(values ...interface{}) string {
+	key, err := e.Encode(values...)
+	if err != nil {
+		panic(err)
+	}
+	return key
+}
+
+func appendValue(b []byte, v interface{}) ([]byte, error) {
+	switch v := v.(type) {
+	case string:
+		return appendString(b, v), nil
+	case []byte:
+		return appendString(b, string(v)), nil
+	case int:
+		return appendInt(b, int64(v)), nil
+	case int8:
+		return appendInt(b, int64(v)), nil
+	case int16:
+		return appendInt(b, int64(v)), nil
+	case int32:
+		return appendInt(b, int64(v)), nil
+	case int64:
+		return appendInt(b, v), nil
+	case uint:
+		return appendUint(b, uint64(v)), nil
+	case uint8:
+		return appendUint(b, uint64(v)), nil
+	case uint16:
+		return appendUint(b, uint64(v)), nil
+	case uint32:
+		return appendUint(b, uint64(v)), nil
+	case uint64:
+		return appendUint(b, v), nil
+	case float32:
+		return appendFloat(b, float64(v)), nil
+	case float64:
+		return appendFloat(b, v), nil
+	case time.Time:
+		return appendTime(b, v), nil
+	}
+	return nil, fmt.Errorf("%w: %T", ErrType, v)
+}
+
+func appendString(b []byte, s string) []byte {
+	b = append(b, tagString)
+	for i := 0; i < len(s); i++ {
+		if s[i] == 0x00 {
+			b = append(b, 0x00, 0xff)
+			continue
+		}
+		b = append(b, s[i])
+	}
+	return append(b, 0x00, 0x01)
+}
+
+func appendInt(b []byte, n int64) []byte {
+	return binary.BigEndian.AppendUint64(append(b, tagInt), uint64(n)^1<<63)
+}
+
+func appendUint(b []byte, n uint64) []byte {
+	return binary.BigEndian.AppendUint64(append(b, tagUint), n)
+}
+
+func appendFloat(b []byte, f float64) []byte {
+	bits := math.Float64bits(f)
+	if bits&(1<<63) != 0 {
+		bits = ^bits
+	} else {
+		bits ^= 1 << 63
+	}
+	return binary.BigEndian.AppendUint64(append(b, tagFloat), bits)
+}
+
+func appendTime(b []byte, t time.Time) []byte {
+	b = binary.BigEndian.AppendUint64(append(b, tagTime), uint64(t.Unix())^1<<63)
+	return binary.BigEndian.AppendUint32(b, uint32(t.Nanosecond()))
+}
+
+// strip replaces the reserved byte, and p, by p and a marker.
+func (e *Encoder) strip(b []byte) string {
+	low, high := e.p, e.reserved
+	if e.reserved < e.p {
+		low, high = e.reserved, e.p
+	}
+	out := make([]byte, 0, len(b))
+	for _, c := range b {
+		switch c {
+		case low:
+			out = append(out, e.p, e.lo)
+		case high:
+			out = append(out, e.p, e.hi)
+		default:
+			out = append(out, c)
+		}
+	}
+	return string(out)
+}
+
+// unstrip reverses strip.
+func (e *Encoder) unstrip(key string) ([]byte, error) {
+	low, high := e.p, e.reserved
+	if e.reserved < e.p {
+		low, high = e.reserved, e.p
+	}
+	b := make([]byte, 0, len(key))
+	for i := 0; i < len(key); i++ {
+		if key[i] != e.p {
+			b = append(b, key[i])
+			continue
+		}
+		if i++; i == len(key) {
+			return nil, ErrKey
+		}
+		switch key[i] {
+		case e.lo:
+			b = append(b, low)
+		case e.hi:
+			b = append(b, high)
+		default:
+			return nil, ErrKey
+		}
+	}
+	return b, nil
+}
+
+// Decode decodes a key back into its tuple.
+func (e *Encoder) Decode(key string) ([]interface{}, error) {
+	b, err := e.unstrip(key)
+	if err != nil {
+		return nil, err
+	}
+	var values []interface{}
+	for len(b) > 0 {
+		var v interface{}
+		if v, b, err = decodeValue(b); err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+	}
+	return values, nil
+}
+
+func decodeValue(b []byte) (interface{}, []byte, error) {
+	tag, b := b[0], b[1:]
+	switch tag {
+	case tagString:
+		var s []byte
+		for i := 0; i+1 < len(b); i++ {
+			if b[i] != 0x00 {
+				s = append(s, b[i])
+				continue
+			}
+			switch b[i+1] {
+			case 0x01:
+				return string(s), b[i+2:], nil
+			case 0xff:
+				s = append(s, 0x00)
+				i++
+			default:
+				return nil, nil, ErrKey
+			}
+		}
+		return nil, nil, ErrKey
+	case tagInt, tagUint, tagFloat:
+		if len(b) < 8 {
+			return nil, nil, ErrKey
+		}
+		n := binary.BigEndian.Uint64(b)
+		switch tag {
+		case tagInt:
+			return int64(n ^ 1<<63), b[8:], nil
+		case tagUint:
+			return n, b[8:], nil
+		}
+		if n&(1<<63) != 0 {
+			n ^= 1 << 63
+		} else {
+			n = ^n
+		}
+		return math.Float64frombits(n), b[8:], nil
+	case tagTime:
+		if len(b) < 12 {
+			return nil, nil, ErrKey
+		}
+		sec := int64(binary.BigEndian.Uint64(b) ^ 1<<63)
+		nsec := int64(binary.BigEndian.Uint32(b[8:]))
+		return time.Unix(sec, nsec).UTC(), b[12:], nil
+	}
+	return nil, nil, ErrKey
+}
+
+// Walk visits the labels of a tree in ascending order, which is the order
+// of their tuples, decoding them, until fn returns false. Labels that
+// aren't keys of the encoder are skipped.
+func (e *Encoder) Walk(tr *radix.Tree, fn func(values []interface{}, n *radix.Node) bool) {
+	tr.Walk(e.decoder(fn))
+}
+
+// WalkPrefix is like Walk but only visits the labels
+// of tuples starting with the given values.
+func (e *Encoder) WalkPrefix(tr *radix.Tree, fn func(values []interface{}, n *radix.Node) bool, prefix ...interface{}) error {
+	key, err := e.Encode(prefix...)
+	if err != nil {
+		return err
+	}
+	tr.WalkPrefix(key, e.decoder(fn))
+	return nil
+}
+
+func (e *Encoder) decoder(fn func(values []interface{}, n *radix.Node) bool) func(string, *radix.Node) bool {
+	return func(label string, n *radix.Node) bool {
+		values, err := e.Decode(label)
+		if err != nil {
+			return true
+		}
+		return fn(values, n)
+	}
+}
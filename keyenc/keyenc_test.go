@@ -0,0 +1,138 @@
+package keyenc_test
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/knnat/radix"
+	. "github.com/knnat/radix/keyenc"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEncoder(t *testing.T) {
+	day := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	// Tuples in ascending order.
+	tuples := [][]interface{}{
+		{""},
+		{"", int64(0)},
+		{"\x00"},
+		{"\x00\x00"},
+		{"\x00\x01"},
+		{"\x01"},
+		{"?"},
+		{"?", "x"},
+		{"?@"},
+		{"@"},
+		{"@", int64(-1)},
+		{"@\x00"},
+		{"A"},
+		{"acme", int64(math.MinInt64)},
+		{"acme", int64(-1)},
+		{"acme", int64(0)},
+		{"acme", int64(64)},
+		{"acme", int64(math.MaxInt64)},
+		{"acme", uint64(0)},
+		{"acme", uint64(0x40)},
+		{"acme", uint64(0x4040)},
+		{"acme", uint64(math.MaxUint64)},
+		{"acme", math.Inf(-1)},
+		{"acme", -2.5},
+		{"acme", math.Copysign(0, -1)},
+		{"acme", 0.0},
+		{"acme", 1e-300},
+		{"acme", 2.5},
+		{"acme", math.Inf(1)},
+		{"acme", time.Date(1900, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"acme", day},
+		{"acme", day, uint64(1)},
+		{"acme", day, uint64(2)},
+		{"acme", day.Add(time.Nanosecond)},
+		{"acme", day.Add(time.Second)},
+		{"acme\x00"},
+		{"acme\xff"},
+		{"\xff"},
+	}
+	for _, reserved := range []byte{'@', ':', '{', 0x00, 0x01, 0x02, 0xfe, 0xff} {
+		enc := New(reserved)
+		var keys []string
+		for _, tuple := range tuples {
+			key, err := enc.Encode(tuple...)
+			assert.Nil(t, err)
+			assert.NotContains(t, key, string(reserved))
+			values, err := enc.Decode(key)
+			assert.Nil(t, err)
+			assert.Equal(t, tuple, values)
+			keys = append(keys, key)
+		}
+		assert.True(t, sort.StringsAreSorted(keys), "reserved %#x", reserved)
+		for i := 1; i < len(keys); i++ {
+			assert.NotEqual(t, keys[i-1], keys[i])
+		}
+		prefix := enc.MustEncode("acme", day)
+		assert.True(t, strings.HasPrefix(enc.MustEncode("acme", day, uint64(1)), prefix))
+	}
+
+	enc := New('@')
+	key, err := enc.Encode(1, int8(-2), uint8(3), float32(0.5), []byte("b"))
+	assert.Nil(t, err)
+	values, err := enc.Decode(key)
+	assert.Nil(t, err)
+	assert.Equal(t, []interface{}{int64(1), int64(-2), uint64(3), 0.5, "b"}, values)
+	local := time.Date(2024, 3, 1, 13, 0, 0, 5, time.FixedZone("CET", 3600))
+	values, err = enc.Decode(enc.MustEncode(local))
+	assert.Nil(t, err)
+	assert.Equal(t, []interface{}{local.UTC()}, values)
+
+	_, err = enc.Encode(struct{}{})
+	assert.True(t, errors.Is(err, ErrType))
+	for _, key := range []string{"\x01", "\x02a", "\x03\x00", "\x3f", "\x3f\x05"} {
+		_, err = enc.Decode(key)
+		assert.Equal(t, ErrKey, err, "%q", key)
+	}
+}
+
+func TestEncoderTree(t *testing.T) {
+	enc := New('@')
+	tr := radix.New()
+	day := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	for i, tuple := range [][]interface{}{
+		{"globex", day, uint64(1)},
+		{"acme", day.Add(time.Hour), uint64(3)},
+		{"acme", day, uint64(2)},
+		{"acme@eu", day, uint64(1)},
+		{"acme", day, uint64(1)},
+	} {
+		assert.Nil(t, tr.Add(enc.MustEncode(tuple...), i))
+	}
+	n, _ := tr.Get(enc.MustEncode("acme@eu", day, uint64(1)))
+	if assert.NotNil(t, n) {
+		assert.Equal(t, 3, n.Value)
+	}
+
+	var walked []interface{}
+	enc.Walk(tr, func(values []interface{}, n *radix.Node) bool {
+		walked = append(walked, values[0], values[2])
+		return true
+	})
+	assert.Equal(t, []interface{}{
+		"acme", uint64(1),
+		"acme", uint64(2),
+		"acme", uint64(3),
+		"acme@eu", uint64(1),
+		"globex", uint64(1),
+	}, walked)
+
+	var ids []interface{}
+	err := enc.WalkPrefix(tr, func(values []interface{}, n *radix.Node) bool {
+		ids = append(ids, values[2])
+		return true
+	}, "acme", day)
+	assert.Nil(t, err)
+	assert.Equal(t, []interface{}{uint64(1), uint64(2)}, ids)
+
+	assert.True(t, errors.Is(enc.WalkPrefix(tr, nil, 1i), ErrType))
+}
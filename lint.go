@@ -0,0 +1,359 @@
+package radix
+
+import (
+	"fmt"
+	"regexp/syntax"
+	"sort"
+	"strings"
+)
+
+// LintKind is the kind of a problem found by Lint.
+type LintKind uint8
+
+const (
+	// LintInvalid is a malformed label, which Add rejects.
+	LintInvalid LintKind = iota
+	// LintDuplicate is a label listed more than once.
+	LintDuplicate
+	// LintAmbiguous is a label whose placeholders can't be told apart
+	// from another label's, which Add rejects, or a label using the
+	// same placeholder name twice.
+	LintAmbiguous
+	// LintShadowed is a label whose matches all go to another label.
+	LintShadowed
+	// LintUnreachable is a label nothing matches, even on its own.
+	LintUnreachable
+)
+
+func (k LintKind) String() string {
+	switch k {
+	case LintInvalid:
+		return "invalid"
+	case LintDuplicate:
+		return "duplicate"
+	case LintAmbiguous:
+		return "ambiguous"
+	case LintShadowed:
+		return "shadowed"
+	case LintUnreachable:
+		return "unreachable"
+	}
+	return fmt.Sprintf("LintKind(%d)", uint8(k))
+}
+
+// LintIssue is a problem found by Lint.
+type LintIssue struct {
+	Kind        LintKind
+	Label       string
+	Other       string // the label it conflicts with or is shadowed by, if any
+	Explanation string
+}
+
+func (i LintIssue) String() string {
+	return fmt.Sprintf("%s: %s: %s", i.Label, i.Kind, i.Explanation)
+}
+
+// Lint checks a set of labels, as they would be added to a tree with the
+// given settings, or the default ones if s is nil, without adding them, and
+// returns every problem found, in the order of the labels.
+//
+// Shadowed and unreachable labels are found by looking up labels built from
+// each one's placeholders, filled with sample values matching their
+// constraints, so that a label is reported as shadowed when every sample is
+// matched by another label first, and as unreachable when no sample is
+// matched by it even on its own.
+func Lint(labels []string, s *Settings) []LintIssue {
+	if s == nil {
+		s = defaults
+	}
+	// The lint tree is only used here, but splits edges as the tree
+	// the labels are added to does.
+	ss := *s
+	ss.Flags = s.Flags&^(Tsafe|Tatomic|Tdebug) | Tnocolor
+	tr := ss.New()
+	var (
+		issues   []LintIssue
+		at       []int // indexes of the issues' labels
+		accepted []int
+		seen     = make(map[string]int, len(labels))
+	)
+	report := func(i int, issue LintIssue) {
+		issues = append(issues, issue)
+		at = append(at, i)
+	}
+	for i, l := range labels {
+		if first, ok := seen[l]; ok {
+			report(i, LintIssue{
+				Kind:        LintDuplicate,
+				Label:       l,
+				Other:       labels[first],
+				Explanation: fmt.Sprintf("listed at %d and again at %d", first, i),
+			})
+			continue
+		}
+		seen[l] = i
+		if l == "" {
+			report(i, LintIssue{Kind: LintInvalid, Label: l, Explanation: "empty label"})
+			continue
+		}
+		if err := tr.validate(l); err != nil {
+			report(i, LintIssue{Kind: LintInvalid, Label: l, Explanation: err.Error()})
+			continue
+		}
+		if name, ok := tr.repeatedName(l); ok {
+			report(i, LintIssue{
+				Kind:        LintAmbiguous,
+				Label:       l,
+				Explanation: fmt.Sprintf("placeholder name %q is used more than once, so only one of its values is kept", name),
+			})
+			continue
+		}
+		if err := tr.Add(l, i); err != nil {
+			issue := LintIssue{Kind: LintAmbiguous, Label: l, Explanation: "can't be told apart from another label"}
+			for _, j := range accepted {
+				pair := ss.New()
+				pair.Add(labels[j], j)
+				if pair.Add(l, i) != nil {
+					issue.Other = labels[j]
+					issue.Explanation = fmt.Sprintf("can't be told apart from %q, as both would match the same labels", labels[j])
+					break
+				}
+			}
+			report(i, issue)
+			continue
+		}
+		accepted = append(accepted, i)
+	}
+	for _, i := range accepted {
+		if issue, ok := tr.lintReach(&ss, labels, i); ok {
+			report(i, issue)
+		}
+	}
+	// Keep issues in the order of the labels they are about.
+	sort.Stable(lintSorter{issues, at})
+	return issues
+}
+
+type lintSorter struct {
+	issues []LintIssue
+	at     []int
+}
+
+func (s lintSorter) Len() int           { return len(s.issues) }
+func (s lintSorter) Less(i, j int) bool { return s.at[i] < s.at[j] }
+func (s lintSorter) Swap(i, j int) {
+	s.issues[i], s.issues[j] = s.issues[j], s.issues[i]
+	s.at[i], s.at[j] = s.at[j], s.at[i]
+}
+
+// lintReach checks whether the label at index i, which is in the tree,
+// is matched by any of its samples, on its own and then in the tree.
+func (tr *Tree) lintReach(s *Settings, labels []string, i int) (LintIssue, bool) {
+	l := labels[i]
+	samples, name, ok := tr.samples(l)
+	if !ok {
+		return LintIssue{
+			Kind:        LintUnreachable,
+			Label:       l,
+			Explanation: fmt.Sprintf("placeholder %q matches no value", name),
+		}, true
+	}
+	alone := s.New()
+	alone.Add(l, i)
+	var example string
+	other := -1
+	reachable := false
+	for _, sample := range samples {
+		if n, _ := alone.Get(sample); n == nil {
+			continue
+		}
+		reachable = true
+		n, _ := tr.Get(sample)
+		if n != nil && n.Value == i {
+			return LintIssue{}, false
+		}
+		if other < 0 && n != nil {
+			example, other = sample, n.Value.(int)
+		}
+	}
+	if !reachable {
+		return LintIssue{
+			Kind:        LintUnreachable,
+			Label:       l,
+			Explanation: "no label is matched by it",
+		}, true
+	}
+	if other < 0 {
+		return LintIssue{}, false
+	}
+	return LintIssue{
+		Kind:        LintShadowed,
+		Label:       l,
+		Other:       labels[other],
+		Explanation: fmt.Sprintf("labels such as %q are matched by %q first", example, labels[other]),
+	}, true
+}
+
+// repeatedName returns the first placeholder name used twice in a label.
+func (tr *Tree) repeatedName(label string) (string, bool) {
+	names := make(map[string]bool)
+	for _, p := range tr.placeholders(label) {
+		if names[p.name] {
+			return p.name, true
+		}
+		names[p.name] = true
+	}
+	return "", false
+}
+
+// placeholders returns the placeholders of a valid label.
+func (tr *Tree) placeholders(label string) []placeholder {
+	var ps []placeholder
+	for i := 0; i < len(label); i++ {
+		if tr.literal(label, i) {
+			i++
+		} else if label[i] == tr.escape {
+			p, _ := tr.placeholder(label, i)
+			ps = append(ps, p)
+			i = p.end - 1
+		}
+	}
+	return ps
+}
+
+// maxSamples caps the number of samples built for a label.
+const maxSamples = 16
+
+// samples builds labels that a valid label should match, filling its
+// placeholders with sample values, unless no value could be found for
+// one of them, in which case it returns its name and false.
+func (tr *Tree) samples(label string) ([]string, string, bool) {
+	params := []map[string]string{{}}
+	for _, p := range tr.placeholders(label) {
+		var values []string
+		// Placeholders ending the label may match several segments.
+		multi := p.end == len(label) && tr.multi(p)
+		for _, v := range tr.sampleValues(p, multi) {
+			if v != "" && (p.spans || multi || strings.IndexByte(v, tr.delim) < 0) && p.matches(v) {
+				values = append(values, v)
+			}
+		}
+		if len(values) == 0 {
+			return nil, p.name, false
+		}
+		var next []map[string]string
+		for _, m := range params {
+			if p.optional {
+				next = append(next, m)
+			}
+			for _, v := range values {
+				c := make(map[string]string, len(m)+1)
+				for k, v := range m {
+					c[k] = v
+				}
+				c[p.name] = v
+				next = append(next, c)
+			}
+		}
+		if len(next) > maxSamples {
+			next = next[:maxSamples]
+		}
+		params = next
+	}
+	var samples []string
+	for _, m := range params {
+		if s, err := tr.BuildPattern(label, m); err == nil {
+			samples = append(samples, s)
+		}
+	}
+	return samples, "", true
+}
+
+// sampleValues returns values that may match a placeholder.
+func (tr *Tree) sampleValues(p placeholder, multi bool) []string {
+	switch {
+	case p.constraint == "" && multi:
+		return []string{p.name, "x", "0", "x" + string(tr.delim) + "y"}
+	case p.constraint == "":
+		return []string{p.name, "x", "0"}
+	case p.constraint == ":int":
+		return []string{"0", "1"}
+	case p.constraint == ":uuid":
+		return []string{"00000000-0000-0000-0000-000000000000"}
+	case p.constraint[0] == '{':
+		re, err := syntax.Parse(p.constraint[1:len(p.constraint)-1], syntax.Perl)
+		if err != nil {
+			return nil
+		}
+		return sampleRegexp(re.Simplify())
+	}
+	return nil
+}
+
+// sampleRegexp returns some of the strings a regular expression matches,
+// favouring short ones, although some may not match it, when it holds
+// assertions such as word boundaries.
+func sampleRegexp(re *syntax.Regexp) []string {
+	switch re.Op {
+	case syntax.OpNoMatch:
+		return nil
+	case syntax.OpLiteral:
+		return []string{string(re.Rune)}
+	case syntax.OpCharClass:
+		var out []string
+		for i := 0; i+1 < len(re.Rune) && len(out) < maxSamples; i += 2 {
+			out = append(out, string(re.Rune[i]))
+			if re.Rune[i+1] != re.Rune[i] {
+				out = append(out, string(re.Rune[i+1]))
+			}
+		}
+		return out
+	case syntax.OpAnyChar, syntax.OpAnyCharNotNL:
+		return []string{"a"}
+	case syntax.OpCapture, syntax.OpPlus:
+		return sampleRegexp(re.Sub[0])
+	case syntax.OpStar, syntax.OpQuest:
+		return append([]string{""}, sampleRegexp(re.Sub[0])...)
+	case syntax.OpRepeat:
+		sub := sampleRegexp(re.Sub[0])
+		out := []string{""}
+		for i := 0; i < re.Min; i++ {
+			out = product(out, sub)
+		}
+		if re.Min == 0 {
+			out = append(out, sub...)
+		}
+		return out
+	case syntax.OpConcat:
+		out := []string{""}
+		for _, sub := range re.Sub {
+			out = product(out, sampleRegexp(sub))
+		}
+		return out
+	case syntax.OpAlternate:
+		var out []string
+		for _, sub := range re.Sub {
+			out = append(out, sampleRegexp(sub)...)
+		}
+		if len(out) > maxSamples {
+			out = out[:maxSamples]
+		}
+		return out
+	}
+	// Empty matches and assertions.
+	return []string{""}
+}
+
+// product returns the concatenations of each of a with each of b.
+func product(a, b []string) []string {
+	var out []string
+	for _, x := range a {
+		for _, y := range b {
+			if len(out) == maxSamples {
+				return out
+			}
+			out = append(out, x+y)
+		}
+	}
+	return out
+}
@@ -0,0 +1,54 @@
+package radix_test
+
+import (
+	"testing"
+
+	. "github.com/knnat/radix"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLint(t *testing.T) {
+	labels := []string{
+		"/users/@id",
+		"/users/@id:int",
+		"/users/new",
+		"/users/@name/posts",
+		"/users/@id",
+		"/posts/@id/@id",
+		"/files/@any",
+		"/files/@name.json",
+		"/ids/@a:int",
+		"/ids/@b{[0-9]+}",
+		"/years/@year{[0-9]{4}}",
+		"/never/@x{}",
+		"/bad/@x{[}",
+		"/bad/@x?/y",
+		"/ok/@a{foo|ba[rz]}",
+	}
+	var got []string
+	for _, issue := range Lint(labels, nil) {
+		got = append(got, issue.Kind.String()+" "+issue.Label+" "+issue.Other)
+		assert.NotEmpty(t, issue.Explanation)
+		assert.Contains(t, issue.String(), issue.Explanation)
+	}
+	assert.Equal(t, []string{
+		"ambiguous /users/@name/posts /users/@id",
+		"duplicate /users/@id /users/@id",
+		"ambiguous /posts/@id/@id ",
+		"shadowed /ids/@b{[0-9]+} /ids/@a:int",
+		"unreachable /never/@x{} ",
+		"invalid /bad/@x{[} ",
+		"invalid /bad/@x?/y ",
+	}, got)
+
+	assert.Empty(t, Lint(constrainedLabels, nil))
+	assert.Empty(t, Lint([]string{"/users/{id}", "/files/{path...}"}, &Settings{Delimiter: '/', Syntax: BraceSyntax}))
+	assert.Empty(t, Lint([]string{"/x/{a...}", "/x/{b}"}, &Settings{Delimiter: '/', Syntax: BraceSyntax}))
+	// Structural flags are kept.
+	runes := &Settings{Flags: Tatomic | Trunes, Escape: '@', Delimiter: '/'}
+	assert.Empty(t, Lint([]string{"/é/@id", "/è/@id"}, runes))
+	issues := Lint([]string{"/é/@id", "/é/@name"}, runes)
+	if assert.Len(t, issues, 1) {
+		assert.Equal(t, LintAmbiguous, issues[0].Kind)
+	}
+}
@@ -0,0 +1,103 @@
+package radix
+
+import (
+	"bytes"
+	"strings"
+)
+
+// ListOptions configures a listing.
+type ListOptions struct {
+	// Marker lists only what comes after it, usually the NextMarker
+	// of the previous page.
+	Marker string
+	// MaxKeys is the maximum number of entries and common prefixes
+	// returned. Zero means no limit.
+	MaxKeys int
+}
+
+// ListResult is a page of a listing.
+type ListResult struct {
+	Entries []Entry
+	// CommonPrefixes are the labels' prefixes up to the first delimiter
+	// after the listed prefix, each standing for all labels starting with it.
+	CommonPrefixes []string
+	// IsTruncated reports whether there is more to list after NextMarker.
+	IsTruncated bool
+	NextMarker  string
+}
+
+// List lists the labels starting with prefix, like a directory, in ascending
+// order. Labels with a delimiter after the prefix are not listed, but rolled
+// up into a common prefix which ends at that delimiter.
+//
+// Subtrees rolled up into a common prefix are not visited.
+// Placeholders are not expanded, labels are compared as they were added.
+func (tr *Tree) List(prefix string, opts ListOptions) ListResult {
+	var res ListResult
+	prefix = tr.normalize(prefix)
+	opts.Marker = tr.normalize(opts.Marker)
+	defer tr.runlock()
+	tnode, label, depth := tr.seek(tr.rlock().root, prefix)
+	if tnode == nil {
+		return res
+	}
+	l := &lister{
+		delim:  tr.delim,
+		marker: opts.Marker,
+		max:    opts.MaxKeys,
+		res:    &res,
+	}
+	l.walk(tnode, depth, label, len(prefix))
+	return res
+}
+
+type lister struct {
+	delim  byte
+	marker string
+	max    int
+	n      int
+	res    *ListResult
+}
+
+// walk lists the node, at the given depth, looking for delimiters in its
+// label from index from, and its children. It reports whether to keep listing.
+func (l *lister) walk(n *Node, depth int, label []byte, from int) bool {
+	if i := bytes.IndexByte(label[from:], l.delim); i >= 0 {
+		return l.add(string(label[:from+i+1]), nil)
+	}
+	if string(label) < l.marker && !strings.HasPrefix(l.marker, string(label)) {
+		return true // all labels of the subtree come before the marker
+	}
+	if n.Value != nil && !l.add(string(label), n.at(depth)) {
+		return false
+	}
+	if len(label) > from {
+		from = len(label)
+	}
+	for e := n.edgeAfter(nil); e != nil; e = n.edgeAfter(e) {
+		if !l.walk(e.node, depth+1, append(label, e.label...), from) {
+			return false
+		}
+	}
+	return true
+}
+
+// add adds an entry or, if n is nil, a common prefix
+// to the listing. It reports whether to keep listing.
+func (l *lister) add(label string, n *Node) bool {
+	if label <= l.marker {
+		return true
+	}
+	if l.max > 0 && l.n == l.max {
+		l.res.IsTruncated = true
+		return false
+	}
+	l.n++
+	l.res.NextMarker = label
+	if n == nil {
+		l.res.CommonPrefixes = append(l.res.CommonPrefixes, label)
+	} else {
+		l.res.Entries = append(l.res.Entries, Entry{Label: label, Node: n})
+	}
+	return true
+}
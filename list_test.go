@@ -0,0 +1,73 @@
+package radix_test
+
+import (
+	"testing"
+
+	. "github.com/knnat/radix"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestList(t *testing.T) {
+	tr := New()
+	for _, l := range []string{
+		"photos/2019/jan/a.jpg", "photos/2019/feb/b.jpg", "photos/2020/c.jpg",
+		"photos/index.html", "photos/", "photos.txt", "videos/d.mp4",
+	} {
+		assert.Nil(t, tr.Add(l, l))
+	}
+	labels := func(entries []Entry) []string {
+		var labels []string
+		for _, e := range entries {
+			assert.Equal(t, e.Label, e.Node.Value)
+			labels = append(labels, e.Label)
+		}
+		return labels
+	}
+
+	res := tr.List("", ListOptions{})
+	assert.Equal(t, []string{"photos.txt"}, labels(res.Entries))
+	assert.Equal(t, []string{"photos/", "videos/"}, res.CommonPrefixes)
+	assert.False(t, res.IsTruncated)
+
+	res = tr.List("photos/", ListOptions{})
+	assert.Equal(t, []string{"photos/", "photos/index.html"}, labels(res.Entries))
+	assert.Equal(t, []string{"photos/2019/", "photos/2020/"}, res.CommonPrefixes)
+
+	res = tr.List("photos/20", ListOptions{})
+	assert.Nil(t, res.Entries)
+	assert.Equal(t, []string{"photos/2019/", "photos/2020/"}, res.CommonPrefixes)
+
+	res = tr.List("photos/2019/", ListOptions{})
+	assert.Equal(t, []string{"photos/2019/feb/", "photos/2019/jan/"}, res.CommonPrefixes)
+
+	res = tr.List("music/", ListOptions{})
+	assert.Equal(t, ListResult{}, res)
+
+	// Paging through a directory.
+	var entries []string
+	var prefixes []string
+	opts := ListOptions{MaxKeys: 1}
+	for pages := 1; ; pages++ {
+		res := tr.List("photos/", opts)
+		assert.Equal(t, 1, len(res.Entries)+len(res.CommonPrefixes))
+		entries = append(entries, labels(res.Entries)...)
+		prefixes = append(prefixes, res.CommonPrefixes...)
+		if !res.IsTruncated {
+			assert.Equal(t, 4, pages)
+			break
+		}
+		opts.Marker = res.NextMarker
+	}
+	assert.Equal(t, []string{"photos/", "photos/index.html"}, entries)
+	assert.Equal(t, []string{"photos/2019/", "photos/2020/"}, prefixes)
+
+	res = tr.List("photos/", ListOptions{Marker: "photos/2019/", MaxKeys: 1})
+	assert.Nil(t, res.Entries)
+	assert.Equal(t, []string{"photos/2020/"}, res.CommonPrefixes)
+	assert.True(t, res.IsTruncated)
+	assert.Equal(t, "photos/2020/", res.NextMarker)
+
+	res = tr.List("photos/", ListOptions{Marker: "photos/h"})
+	assert.Equal(t, []string{"photos/index.html"}, labels(res.Entries))
+	assert.Nil(t, res.CommonPrefixes)
+}
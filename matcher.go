@@ -0,0 +1,357 @@
+package radix
+
+import (
+	"bufio"
+	"io"
+	"sort"
+	"unicode/utf8"
+)
+
+// Match is a label found in a text by a matcher.
+type Match struct {
+	Label  string
+	Value  interface{}
+	Offset int // byte offset of the match in the text
+}
+
+// Matcher is an Aho-Corasick automaton that finds all labels
+// of a tree in a text in a single pass.
+//
+// It follows the compressed edges of the tree, rather than a trie with
+// a node per byte, and only keeps the failure links of the positions
+// in them. It is built from a snapshot of the tree, so changes made to
+// the tree afterwards don't affect it, and it is safe for concurrent use.
+type Matcher struct {
+	nodes   []mnode
+	edges   []medge // the first one leads to the root
+	fail    []pos   // longest proper suffix of each position that is a position too
+	out     []int32 // longest proper suffix of each position that holds a value, or -1
+	keys    []Match // labels and values of the nodes that hold one
+	keyPos  []int32 // the position of each key
+	norm    KeyNormalizer
+	longest int // length of the longest label
+}
+
+// mnode is a node of a matcher.
+type mnode struct {
+	edges []transition // sorted by byte
+	key   int32        // index of the node's label in keys, or -1
+}
+
+// transition is an edge of a matcher's node and the first byte of its label.
+type transition struct {
+	c byte
+	e int32
+}
+
+// medge is an edge of a matcher. Its positions, which are reached by each
+// byte of its label, are numbered from base on, the last one being its node.
+type medge struct {
+	label string
+	node  int32
+	base  int32
+}
+
+// pos is a position in a matcher, after the first off bytes of an edge's
+// label, which is the edge's node when the whole label is behind it.
+type pos struct {
+	e, off int32
+}
+
+// segment is the part of a tree's edge that is left to add to a matcher,
+// which is the tree's node itself once no label is left.
+type segment struct {
+	label string
+	node  *Node
+}
+
+// Matcher builds an Aho-Corasick automaton from the labels of the tree.
+//
+// Placeholders are not expanded, labels are matched as they were added.
+// If the tree has a key normalizer, texts are matched as it maps them,
+// but the offsets of matches are those in the texts as they were given.
+func (tr *Tree) Matcher() *Matcher {
+	defer tr.runlock()
+	m := &Matcher{norm: tr.norm}
+	m.edges = append(m.edges, medge{base: 1})
+	m.fail = append(m.fail, pos{})
+	m.out = append(m.out, -1)
+	m.build("", []segment{{node: tr.rlock().root}})
+	m.link()
+	return m
+}
+
+// build adds a node to the matcher for the segments reached by the same
+// label, which are more than one only where edges share their first bytes,
+// and returns its index.
+func (m *Matcher) build(label string, segs []segment) int32 {
+	id := int32(len(m.nodes))
+	m.nodes = append(m.nodes, mnode{key: -1})
+	var next []segment
+	for _, s := range segs {
+		if s.label != "" {
+			next = append(next, s)
+			continue
+		}
+		if s.node.Value != nil && label != "" {
+			m.nodes[id].key = int32(len(m.keys))
+			m.keys = append(m.keys, Match{Label: label, Value: s.node.Value})
+			m.keyPos = append(m.keyPos, -1)
+			if len(label) > m.longest {
+				m.longest = len(label)
+			}
+		}
+		for e := s.node.edgeAfter(nil); e != nil; e = s.node.edgeAfter(e) {
+			next = append(next, segment{label: e.label, node: e.node})
+		}
+	}
+	sort.SliceStable(next, func(i, j int) bool {
+		return next[i].label[0] < next[j].label[0]
+	})
+	var edges []transition
+	for i := 0; i < len(next); {
+		j := i + 1
+		for j < len(next) && next[j].label[0] == next[i].label[0] {
+			j++
+		}
+		// Segments sharing their first byte share the edge to their common prefix.
+		prefix := next[i].label
+		for _, s := range next[i+1 : j] {
+			prefix = prefix[:commonPrefix(prefix, s.label)]
+		}
+		rest := make([]segment, j-i)
+		for k, s := range next[i:j] {
+			rest[k] = segment{label: s.label[len(prefix):], node: s.node}
+		}
+		e := int32(len(m.edges))
+		m.edges = append(m.edges, medge{label: prefix, base: int32(len(m.fail))})
+		for k := 0; k < len(prefix); k++ {
+			m.fail = append(m.fail, pos{})
+			m.out = append(m.out, -1)
+		}
+		n := m.build(label+prefix, rest)
+		m.edges[e].node = n
+		if k := m.nodes[n].key; k >= 0 {
+			m.keyPos[k] = m.id(pos{e: e, off: int32(len(prefix))})
+		}
+		edges = append(edges, transition{c: prefix[0], e: e})
+		i = j
+	}
+	m.nodes[id].edges = edges
+	return id
+}
+
+// commonPrefix returns the length of the common prefix of a and b.
+func commonPrefix(a, b string) int {
+	i := 0
+	for i < len(a) && i < len(b) && a[i] == b[i] {
+		i++
+	}
+	return i
+}
+
+// link sets the failure links of all positions, in breadth-first order,
+// so that the links of shorter positions are set before they are used.
+func (m *Matcher) link() {
+	type item struct {
+		p, parent pos
+	}
+	var queue []item
+	push := func(parent pos) {
+		e := m.edges[parent.e]
+		if int(parent.off) < len(e.label) {
+			queue = append(queue, item{p: pos{e: parent.e, off: parent.off + 1}, parent: parent})
+			return
+		}
+		for _, t := range m.nodes[e.node].edges {
+			queue = append(queue, item{p: pos{e: t.e, off: 1}, parent: parent})
+		}
+	}
+	push(pos{})
+	for i := 0; i < len(queue); i++ {
+		it := queue[i]
+		id := m.id(it.p)
+		if it.parent != (pos{}) {
+			c := m.edges[it.p.e].label[it.p.off-1]
+			for f := m.fail[m.id(it.parent)]; ; f = m.fail[m.id(f)] {
+				if to, ok := m.step(f, c); ok {
+					m.fail[id] = to
+					break
+				}
+				if f == (pos{}) {
+					break
+				}
+			}
+		}
+		f := m.fail[id]
+		if k := m.key(f); k >= 0 {
+			m.out[id] = k
+		} else {
+			m.out[id] = m.out[m.id(f)]
+		}
+		push(it.p)
+	}
+}
+
+// id returns the index of a position in fail and out.
+func (m *Matcher) id(p pos) int32 {
+	if p.e == 0 {
+		return 0
+	}
+	return m.edges[p.e].base + p.off - 1
+}
+
+// key returns the index of the label of the node at a position in keys,
+// or -1 if the position is in the middle of an edge or holds no value.
+func (m *Matcher) key(p pos) int32 {
+	e := m.edges[p.e]
+	if int(p.off) < len(e.label) {
+		return -1
+	}
+	return m.nodes[e.node].key
+}
+
+// step returns the position reached from p by c, if any.
+func (m *Matcher) step(p pos, c byte) (pos, bool) {
+	e := m.edges[p.e]
+	if int(p.off) < len(e.label) {
+		if e.label[p.off] == c {
+			return pos{e: p.e, off: p.off + 1}, true
+		}
+		return pos{}, false
+	}
+	edges := m.nodes[e.node].edges
+	i := sort.Search(len(edges), func(i int) bool { return edges[i].c >= c })
+	if i < len(edges) && edges[i].c == c {
+		return pos{e: edges[i].e, off: 1}, true
+	}
+	return pos{}, false
+}
+
+// feed moves the automaton from a position by a byte of the text
+// that ends at end, and calls fn for each label that ends there,
+// longest first, until fn returns false.
+func (m *Matcher) feed(p pos, c byte, end int, fn func(Match) bool) (pos, bool) {
+	for {
+		if to, ok := m.step(p, c); ok {
+			p = to
+			break
+		}
+		if p == (pos{}) {
+			break
+		}
+		p = m.fail[m.id(p)]
+	}
+	k := m.key(p)
+	if k < 0 {
+		k = m.out[m.id(p)]
+	}
+	for ; k >= 0; k = m.out[m.keyPos[k]] {
+		match := m.keys[k]
+		match.Offset = end - len(match.Label)
+		if !fn(match) {
+			return p, false
+		}
+	}
+	return p, true
+}
+
+// FindAll returns all labels found in the text, in order of where they
+// end in the text and, for labels that end at the same byte, longest first.
+func (m *Matcher) FindAll(text string) []Match {
+	var matches []Match
+	sr := m.norm.search(text)
+	collect := func(k Match) bool {
+		k.Offset = sr.offset(k.Offset)
+		matches = append(matches, k)
+		return true
+	}
+	var s pos
+	for i := 0; i < len(sr.key); i++ {
+		s, _ = m.feed(s, sr.key[i], i+1, collect)
+	}
+	return matches
+}
+
+// Scan reads r until EOF and calls fn for each label found, in the same
+// order as FindAll, until fn returns false. Offsets are counted from
+// the beginning of r. Errors other than io.EOF are returned.
+func (m *Matcher) Scan(r io.Reader, fn func(m Match) bool) error {
+	if m.norm != nil {
+		return m.scanRunes(r, fn)
+	}
+	br, ok := r.(io.ByteReader)
+	if !ok {
+		br = bufio.NewReader(r)
+	}
+	var s pos
+	for i := 1; ; i++ {
+		c, err := br.ReadByte()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if s, ok = m.feed(s, c, i, fn); !ok {
+			return nil
+		}
+	}
+}
+
+// runeReader reads both runes and, to keep invalid ones, bytes.
+type runeReader interface {
+	io.RuneScanner
+	io.ByteReader
+}
+
+// scanRunes is Scan for matchers with a key normalizer, which the
+// runes of r are mapped by before being fed to the automaton.
+func (m *Matcher) scanRunes(r io.Reader, fn func(m Match) bool) error {
+	rr, ok := r.(runeReader)
+	if !ok {
+		rr = bufio.NewReader(r)
+	}
+	// offs holds the offsets in r of the last bytes fed to
+	// the automaton, from the one at index base, and always
+	// at least as many as the longest label has.
+	var (
+		s         pos
+		offs      []int
+		base, off int
+		buf       []byte
+	)
+	remap := func(k Match) bool {
+		k.Offset = offs[k.Offset-base]
+		return fn(k)
+	}
+	for {
+		c, size, err := rr.ReadRune()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		buf = buf[:0]
+		if c == utf8.RuneError && size == 1 {
+			rr.UnreadRune()
+			b, _ := rr.ReadByte()
+			buf = append(buf, b)
+		} else {
+			buf = utf8.AppendRune(buf, m.norm(c))
+		}
+		if len(offs) > 2*m.longest {
+			n := copy(offs, offs[len(offs)-m.longest:])
+			base += len(offs) - n
+			offs = offs[:n]
+		}
+		for _, b := range buf {
+			offs = append(offs, off)
+			if s, ok = m.feed(s, b, base+len(offs), remap); !ok {
+				return nil
+			}
+		}
+		off += size
+	}
+}
@@ -0,0 +1,99 @@
+package radix_test
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	. "github.com/knnat/radix"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatcher(t *testing.T) {
+	tr := New()
+	for _, l := range []string{"he", "she", "his", "hers", "usher", "h"} {
+		assert.Nil(t, tr.Add(l, strings.ToUpper(l)))
+	}
+	m := tr.Matcher()
+	// Changes made after building the matcher don't affect it.
+	assert.Nil(t, tr.Add("ushers", "USHERS"))
+	tr.Del("his")
+
+	matches := m.FindAll("ushers his")
+	assert.Equal(t, []Match{
+		{Label: "h", Value: "H", Offset: 2},
+		{Label: "she", Value: "SHE", Offset: 1},
+		{Label: "he", Value: "HE", Offset: 2},
+		{Label: "usher", Value: "USHER", Offset: 0},
+		{Label: "hers", Value: "HERS", Offset: 2},
+		{Label: "h", Value: "H", Offset: 7},
+		{Label: "his", Value: "HIS", Offset: 7},
+	}, matches)
+	assert.Nil(t, m.FindAll("xyz"))
+	assert.Nil(t, New().Matcher().FindAll("ushers"))
+
+	var scanned []Match
+	err := m.Scan(iotest.OneByteReader(strings.NewReader("ushers his")), func(m Match) bool {
+		scanned = append(scanned, m)
+		return true
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, matches, scanned)
+
+	// Scanning stops when fn returns false.
+	var n int
+	assert.Nil(t, m.Scan(bytes.NewBufferString("ushers his"), func(Match) bool {
+		n++
+		return n < 3
+	}))
+	assert.Equal(t, 3, n)
+
+	errRead := errors.New("read error")
+	err = m.Scan(iotest.DataErrReader(iotest.ErrReader(errRead)), func(Match) bool { return true })
+	assert.Equal(t, errRead, err)
+}
+
+func TestMatcherBruteForce(t *testing.T) {
+	tr := New()
+	keys := benchKeys[:500]
+	for _, k := range keys {
+		assert.Nil(t, tr.Set(k, k))
+	}
+	var text strings.Builder
+	for i, k := range benchKeys[:200] {
+		text.WriteString(k[i%len(k):])
+		text.WriteString(k[:i%len(k)])
+	}
+	s := text.String()
+	want := make(map[Match]bool)
+	for _, k := range keys {
+		for i := 0; i+len(k) <= len(s); i++ {
+			if s[i:i+len(k)] == k {
+				want[Match{Label: k, Value: k, Offset: i}] = true
+			}
+		}
+	}
+	got := make(map[Match]bool)
+	for _, m := range tr.Matcher().FindAll(s) {
+		assert.Equal(t, m.Label, s[m.Offset:m.Offset+len(m.Label)])
+		got[m] = true
+	}
+	assert.Equal(t, want, got)
+}
+
+func TestMatcherSharedPrefixes(t *testing.T) {
+	tr := New()
+	// Placeholders share their first bytes within a node.
+	for _, l := range []string{"@id:int", "@id{[a-z]+}", "@name", "x@n", "d:i"} {
+		assert.Nil(t, tr.Add(l, l))
+	}
+	assert.Equal(t, []Match{
+		{Label: "x@n", Value: "x@n", Offset: 0},
+		{Label: "@name", Value: "@name", Offset: 1},
+		{Label: "d:i", Value: "d:i", Offset: 8},
+		{Label: "@id:int", Value: "@id:int", Offset: 6},
+		{Label: "@id{[a-z]+}", Value: "@id{[a-z]+}", Offset: 13},
+	}, tr.Matcher().FindAll("x@name@id:int@id{[a-z]+}"))
+}
@@ -4,11 +4,22 @@ import (
 	"sort"
 )
 
+// indexMin is the number of edges from which a node
+// starts indexing its edges by their first byte.
+//
+// Smaller nodes are scanned linearly, which is faster
+// than an indirection when there are only a few edges.
+const indexMin = 8
+
 // Node is a node of a radix tree.
 type Node struct {
-	Value interface{}
-	edges []*edge
-	depth int
+	Value  interface{}
+	edges  []*edge
+	index  *[256]*edge // edges by their labels' first byte, only for wide nodes
+	shared bool        // whether some edges share their first byte
+	depth  int
+	weight float64
+	best   float64 // highest weight in the subtree
 }
 
 // Depth returns the node's depth.
@@ -16,12 +27,237 @@ func (n *Node) Depth() int {
 	return n.depth
 }
 
+// Weight returns the node's weight.
+func (n *Node) Weight() float64 {
+	return n.weight
+}
+
 // IsLeaf returns whether the node is a leaf.
 func (n *Node) IsLeaf() bool {
 	length := len(n.edges)
 	return length == 0
 }
 
+// child returns the edge whose label starts with c.
+//
+// Edges of the same node only share their first byte when they start with
+// different placeholders, in which case the one with the lowest label is
+// returned and the others can be reached with nextChild.
+func (n *Node) child(c byte) *edge {
+	if n.index != nil {
+		return n.index[c]
+	}
+	var child *edge
+	for _, e := range n.edges {
+		if e.label[0] == c {
+			if !n.shared {
+				return e
+			}
+			if child == nil || e.label < child.label {
+				child = e
+			}
+		}
+	}
+	return child
+}
+
+// nextChild returns the edge that follows prev in ascending
+// order of their labels among the edges sharing its first byte.
+func (n *Node) nextChild(prev *edge) *edge {
+	if !n.shared {
+		return nil
+	}
+	var next *edge
+	for _, e := range n.edges {
+		if e.label[0] == prev.label[0] && e.label > prev.label && (next == nil || e.label < next.label) {
+			next = e
+		}
+	}
+	return next
+}
+
+// addEdge adds a new edge to the node, making sure edges
+// whose labels start with the escape symbol stay placed last.
+//
+// Example:
+//
+//	(root) -> ("users", v1)
+//	       -> ("@uid", v2)
+//	then add ("all", v3)
+//	(root) -> ("users", v1)
+//	       -> ("all", v3)
+//	       -> ("@uid", v2)
+func (n *Node) addEdge(e *edge, escape byte) {
+	i := len(n.edges)
+	for i > 0 && n.edges[i-1].label[0] == escape && e.label[0] != escape {
+		i--
+	}
+	c := n.child(e.label[0])
+	n.shared = n.shared || c != nil
+	n.edges = append(n.edges, nil)
+	copy(n.edges[i+1:], n.edges[i:])
+	n.edges[i] = e
+	if n.index != nil {
+		if c == nil || e.label < c.label {
+			n.index[e.label[0]] = e
+		}
+		return
+	}
+	if len(n.edges) >= indexMin {
+		n.reindex()
+	}
+}
+
+// delEdge removes an edge from the node.
+func (n *Node) delEdge(e *edge) {
+	for i := range n.edges {
+		if n.edges[i] == e {
+			n.edges = append(n.edges[:i], n.edges[i+1:]...)
+			break
+		}
+	}
+	if n.index == nil {
+		return
+	}
+	if len(n.edges) < indexMin {
+		n.index = nil
+		return
+	}
+	if n.index[e.label[0]] == e {
+		n.index[e.label[0]] = nil
+		if n.shared {
+			n.reindex()
+		}
+	}
+}
+
+// setEdges replaces all edges of the node.
+func (n *Node) setEdges(edges ...*edge) {
+	n.edges = edges
+	n.index = nil
+	n.shared = false
+	for i := 1; i < len(edges) && !n.shared; i++ {
+		for _, e := range edges[:i] {
+			if e.label[0] == edges[i].label[0] {
+				n.shared = true
+				break
+			}
+		}
+	}
+	if len(edges) >= indexMin {
+		n.reindex()
+	}
+}
+
+// reindex rebuilds the first byte index from scratch.
+func (n *Node) reindex() {
+	n.index = new([256]*edge)
+	for _, e := range n.edges {
+		if c := n.index[e.label[0]]; c == nil || e.label < c.label {
+			n.index[e.label[0]] = e
+		}
+	}
+}
+
+// edgeAfter returns the edge that follows prev in ascending
+// order of their labels, or the first edge if prev is nil.
+func (n *Node) edgeAfter(prev *edge) *edge {
+	c := -1
+	if prev != nil {
+		if next := n.nextChild(prev); next != nil {
+			return next
+		}
+		c = int(prev.label[0])
+	}
+	if n.index != nil {
+		for i := c + 1; i < len(n.index); i++ {
+			if e := n.index[i]; e != nil {
+				return e
+			}
+		}
+		return nil
+	}
+	var next *edge
+	for _, e := range n.edges {
+		if int(e.label[0]) > c && (next == nil || e.label < next.label) {
+			next = e
+		}
+	}
+	return next
+}
+
+// walk calls fn for the node, at the given depth, and its children
+// in ascending order of their labels, until fn returns false.
+func (n *Node) walk(label []byte, depth int, fn func(label string, n *Node) bool) bool {
+	if n.Value != nil && !fn(string(label), n.at(depth)) {
+		return false
+	}
+	for e := n.edgeAfter(nil); e != nil; e = n.edgeAfter(e) {
+		if !e.node.walk(append(label, e.label...), depth+1, fn) {
+			return false
+		}
+	}
+	return true
+}
+
+// copy copies the node, so that its edges can be changed
+// without changing the original node's.
+func (n *Node) copy() *Node {
+	c := *n
+	c.edges = make([]*edge, len(n.edges))
+	copy(c.edges, n.edges)
+	if n.index != nil {
+		index := *n.index
+		c.index = &index
+	}
+	return &c
+}
+
+// copyTree copies the node and all of its children,
+// placing the copy at the given depth.
+func (n *Node) copyTree(depth int) *Node {
+	c := *n
+	c.depth = depth
+	c.edges = make([]*edge, len(n.edges))
+	for i, e := range n.edges {
+		c.edges[i] = &edge{
+			label: e.label,
+			node:  e.node.copyTree(depth + 1),
+		}
+	}
+	c.index = nil
+	if n.index != nil {
+		c.reindex()
+	}
+	return &c
+}
+
+// replaceEdge replaces an edge of the node with
+// another one whose label starts with the same byte.
+func (n *Node) replaceEdge(old, e *edge) {
+	for i := range n.edges {
+		if n.edges[i] == old {
+			n.edges[i] = e
+		}
+	}
+	if n.index != nil && n.index[e.label[0]] == old {
+		n.index[e.label[0]] = e
+	}
+}
+
+// at returns the node or, if its depth is stale, a copy of it at the given
+// depth. Trees read without locks share the subtrees of their versions, whose
+// depths aren't updated when an edge above them is split or merged, so
+// readers work depths out on their way down instead.
+func (n *Node) at(depth int) *Node {
+	if n.depth == depth {
+		return n
+	}
+	c := *n
+	c.depth = depth
+	return &c
+}
+
 func (n *Node) clone() *Node {
 	c := *n // https://stackoverflow.com/questions/27084401/how-does-pointer-dereferencing-work-in-golang
 	c.incrDepth()
@@ -35,6 +271,27 @@ func (n *Node) incrDepth() {
 	}
 }
 
+func (n *Node) decrDepth() {
+	n.depth--
+	for _, e := range n.edges {
+		e.node.decrDepth()
+	}
+}
+
+func (n *Node) shiftDepth(by int) {
+	n.depth += by
+	for _, e := range n.edges {
+		e.node.shiftDepth(by)
+	}
+}
+
+func (n *Node) setDepth(depth int) {
+	n.depth = depth
+	for _, e := range n.edges {
+		e.node.setDepth(depth + 1)
+	}
+}
+
 // sort sorts the node and its children recursively.
 func (n *Node) sort(st SortingTechnique) {
 	s := &sorter{
@@ -47,7 +304,7 @@ func (n *Node) sort(st SortingTechnique) {
 	}
 }
 
-func (n *Node) writeTo(bd *builder) {
+func (n *Node) writeTo(bd *printer) {
 	for i, e := range n.edges {
 		e.writeTo(bd, []bool{i == len(n.edges)-1})
 	}
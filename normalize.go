@@ -0,0 +1,162 @@
+package radix
+
+import (
+	"unicode"
+	"unicode/utf8"
+)
+
+// KeyNormalizer maps each rune of labels to the one they are stored and
+// looked up with, such as its lower case, so that labels only differing
+// by runes mapped to the same one are the same label.
+//
+// Placeholders are left untouched, except for their suffixes, and the
+// values they capture keep the runes of the labels being looked up,
+// which are also the ones their constraints check.
+type KeyNormalizer func(r rune) rune
+
+// FoldASCII maps ASCII letters to lower case.
+func FoldASCII(r rune) rune {
+	if 'A' <= r && r <= 'Z' {
+		return r + 'a' - 'A'
+	}
+	return r
+}
+
+// FoldUnicode maps runes by Unicode simple case folding, to the lower case
+// of the smallest rune they are equivalent to, so that "K", "k" and the
+// Kelvin sign are all mapped to "k".
+func FoldUnicode(r rune) rune {
+	if r < utf8.RuneSelf {
+		return FoldASCII(r)
+	}
+	min := r
+	for f := unicode.SimpleFold(r); f != r; f = unicode.SimpleFold(f) {
+		if f < min {
+			min = f
+		}
+	}
+	return unicode.ToLower(min)
+}
+
+// normalize returns a label of the tree, as added, with its runes mapped
+// by the tree's key normalizer, except for its placeholders' own runes.
+func (tr *Tree) normalize(label string) string {
+	if tr.norm == nil {
+		return label
+	}
+	b := make([]byte, 0, len(label))
+	for i := 0; i < len(label); {
+		if tr.literal(label, i) {
+			b = append(b, label[i:i+2]...)
+			i += 2
+			continue
+		}
+		end := len(label)
+		if label[i] == tr.escape {
+			p, err := tr.placeholder(label, i)
+			if err != nil {
+				// Let validation report the label.
+				return label
+			}
+			b = append(b, label[i:p.head]...)
+			i, end = p.head, p.end
+		}
+		for i < end && (label[i] != tr.escape || tr.literal(label, i)) {
+			if tr.literal(label, i) {
+				b = append(b, label[i:i+2]...)
+				i += 2
+				continue
+			}
+			b, i = tr.appendNormal(b, label, i)
+		}
+	}
+	return string(b)
+}
+
+// appendNormal appends the rune of s at i, as mapped by the
+// tree's key normalizer, to b and returns the next rune's index.
+// Invalid bytes are appended as they are.
+func (tr *Tree) appendNormal(b []byte, s string, i int) ([]byte, int) {
+	return tr.norm.append(b, s, i)
+}
+
+// append is appendNormal for the normalizer.
+func (f KeyNormalizer) append(b []byte, s string, i int) ([]byte, int) {
+	r, size := utf8.DecodeRuneInString(s[i:])
+	if r == utf8.RuneError && size <= 1 {
+		return append(b, s[i]), i + 1
+	}
+	if m := f(r); m != r {
+		return utf8.AppendRune(b, m), i + size
+	}
+	return append(b, s[i:i+size]...), i + size
+}
+
+// newSearch returns the search for a label being looked up,
+// whose key is the label mapped by the tree's key normalizer.
+func (tr *Tree) newSearch(label string) search {
+	return tr.norm.search(label)
+}
+
+// search is newSearch for the normalizer, which may be nil.
+func (f KeyNormalizer) search(label string) search {
+	s := search{key: label, orig: label}
+	if f == nil {
+		return s
+	}
+	b := make([]byte, 0, len(label))
+	offs := make([]int, 0, len(label)+1)
+	resized := false
+	for i := 0; i < len(label); {
+		n := len(b)
+		next := 0
+		b, next = f.append(b, label, i)
+		resized = resized || len(b)-n != next-i
+		for j := n; j < len(b); j++ {
+			offs = append(offs, i)
+		}
+		i = next
+	}
+	s.key = string(b)
+	if resized {
+		s.offs = append(offs, len(label))
+	}
+	return s
+}
+
+// fold returns a text, rather than a label, with all of its runes
+// mapped by the tree's key normalizer, placeholders included.
+func (tr *Tree) fold(text string) string {
+	if tr.norm == nil {
+		return text
+	}
+	s := tr.newSearch(text)
+	return s.key
+}
+
+// offset returns the offset in the looked up label
+// of the byte at index i of the search's key.
+func (s *search) offset(i int) int {
+	if s.offs == nil {
+		return i
+	}
+	return s.offs[i]
+}
+
+// original returns the part of the looked up label that the first
+// n bytes of label, the part of the search's key left, stand for.
+func (s *search) original(label string, n int) string {
+	start := len(s.key) - len(label)
+	return s.orig[s.offset(start):s.offset(start+n)]
+}
+
+// capture returns the value a placeholder matches in label[:end], as it
+// is in the looked up label, and whether it matches it at all, which it
+// never does when the value would be empty.
+func (s *search) capture(p placeholder, label string, end int) (string, bool) {
+	if end <= len(p.suffix) || label[end-len(p.suffix):end] != p.suffix {
+		return "", false
+	}
+	v := s.original(label, end-len(p.suffix))
+	return v, p.matches(v)
+}
@@ -0,0 +1,167 @@
+package radix_test
+
+import (
+	"strings"
+	"testing"
+
+	. "github.com/knnat/radix"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKeyNormalizer(t *testing.T) {
+	s := &Settings{Escape: '@', Delimiter: '/', KeyNormalizer: FoldASCII}
+	tr := s.New()
+	assert.Nil(t, tr.Add("/Users/@ID/Posts", 1))
+	assert.Nil(t, tr.Add("/files/@Name.JSON", 2))
+	assert.Nil(t, tr.Add("/codes/@code{[A-Z]+}", 3))
+	assert.Equal(t, ErrEscape, tr.Add("/USERS/@ID/posts", 4))
+
+	for _, tc := range []struct {
+		label  string
+		want   interface{}
+		params map[string]string
+	}{
+		{"/users/Bob/posts", 1, map[string]string{"ID": "Bob"}},
+		{"/USERS/Bob/POSTS", 1, map[string]string{"ID": "Bob"}},
+		{"/Files/Report.Json", 2, map[string]string{"Name": "Report"}},
+		// Constraints check the values as they are looked up.
+		{"/CODES/ABC", 3, map[string]string{"code": "ABC"}},
+		{"/codes/abc", nil, nil},
+	} {
+		n, p := tr.Get(tc.label)
+		if tc.want == nil {
+			assert.Nil(t, n, tc.label)
+			continue
+		}
+		if assert.NotNil(t, n, tc.label) {
+			assert.Equal(t, tc.want, n.Value, tc.label)
+			assert.Equal(t, tc.params, p, tc.label)
+		}
+	}
+	var labels []string
+	tr.Walk(func(label string, n *Node) bool {
+		labels = append(labels, label)
+		return true
+	})
+	assert.Equal(t, []string{"/codes/@code{[A-Z]+}", "/files/@Name.json", "/users/@ID/posts"}, labels)
+	tr.Del("/FILES/@Name.json")
+	n, _ := tr.Get("/files/report.json")
+	assert.Nil(t, n)
+
+	st := s.NewSharded(16)
+	assert.Nil(t, st.Add("Hello/@name", 1))
+	n, p := st.Get("hELLO/World")
+	if assert.NotNil(t, n) {
+		assert.Equal(t, map[string]string{"name": "World"}, p)
+	}
+
+	b := s.NewBuilder(0)
+	assert.Nil(t, b.Add("B", 1))
+	assert.Equal(t, ErrUnsorted, b.Add("a", 2))
+	assert.Nil(t, b.Add("c", 3))
+	n, _ = b.Tree().Get("b")
+	assert.NotNil(t, n)
+}
+
+func TestFoldUnicode(t *testing.T) {
+	for _, rs := range [][]rune{{'K', 'k', '\u212a'}, {'S', 's', 'ſ'}, {'Σ', 'σ', 'ς'}, {'Ä', 'ä'}} {
+		for _, r := range rs {
+			assert.Equal(t, rs[1], FoldUnicode(r), string(r))
+		}
+	}
+	assert.Equal(t, '1', FoldUnicode('1'))
+
+	tr := (&Settings{Escape: '@', Delimiter: '/', KeyNormalizer: FoldUnicode}).New()
+	assert.Nil(t, tr.Add("/straße/@name/ok", 1))
+	// The Kelvin sign is longer than "k", which captured values keep.
+	n, p := tr.Get("/STRAßE/\u212aelvin ÄÖ/OK")
+	if assert.NotNil(t, n) {
+		assert.Equal(t, map[string]string{"name": "\u212aelvin ÄÖ"}, p)
+	}
+	trace := tr.Explain("/Straße/x/OK")
+	assert.Equal(t, map[string]string{"name": "x"}, trace.Params)
+}
+
+func TestNormalizedQueries(t *testing.T) {
+	s := &Settings{Escape: '@', Delimiter: '/', KeyNormalizer: FoldUnicode}
+	tr := s.New()
+	for i, label := range []string{"foo", "foo/bar", "foo/baz", "straße"} {
+		assert.Nil(t, tr.Add(label, i))
+	}
+	assert.True(t, tr.SetWeight("FOO/BAZ", 2))
+	assert.Equal(t, []string{"foo/baz", "foo/bar"}, completions(tr.TopK("Foo/", 2)))
+
+	var labels []string
+	tr.WalkPrefix("FOO/", func(label string, n *Node) bool {
+		labels = append(labels, label)
+		return true
+	})
+	assert.Equal(t, []string{"foo/bar", "foo/baz"}, labels)
+	res := tr.List("FOO/", ListOptions{Marker: "FOO/BAR"})
+	assert.Equal(t, []Entry{{Label: "foo/baz", Node: res.Entries[0].Node}}, res.Entries)
+
+	// Prefixes are slices of the string as it was given.
+	entries := tr.PrefixesOf("FOO/BARS")
+	if assert.Len(t, entries, 2) {
+		assert.Equal(t, "FOO", entries[0].Label)
+		assert.Equal(t, "FOO/BAR", entries[1].Label)
+	}
+	label, _, ok := tr.LongestPrefix("KSTRASSE")
+	assert.False(t, ok, label)
+	label, _, ok = tr.LongestPrefix("STRAẞE!")
+	assert.True(t, ok)
+	assert.Equal(t, "STRAẞE", label)
+
+	tokens, unknown := tr.Tokenize("FOO-STRAẞE")
+	assert.Equal(t, []Token{
+		{Label: "FOO", Node: tokens[0].Node, Offset: 0},
+		{Label: "-", Offset: 3},
+		{Label: "STRAẞE", Node: tokens[2].Node, Offset: 4},
+	}, tokens)
+	assert.Equal(t, []Token{{Label: "-", Offset: 3}}, unknown)
+	tokens, _ = tr.TokenizeMin("Foo/BazStraße")
+	assert.Equal(t, []string{"Foo/Baz", "Straße"}, []string{tokens[0].Label, tokens[1].Label})
+
+	entries, err := tr.MatchGlob("FOO/*")
+	assert.Nil(t, err)
+	assert.Len(t, entries, 2)
+	assert.Len(t, tr.Fuzzy("FOO/BAX", 1), 2)
+
+	// The capital sharp s is longer than its lower case.
+	m := tr.Matcher()
+	want := []Match{{Label: "foo", Value: 0, Offset: 3}, {Label: "straße", Value: 3, Offset: 6}}
+	assert.Equal(t, want, m.FindAll("ẞFOOSTRAẞE"))
+	var got []Match
+	assert.Nil(t, m.Scan(strings.NewReader("ẞFOOSTRAẞE"), func(k Match) bool {
+		got = append(got, k)
+		return true
+	}))
+	assert.Equal(t, want, got)
+	// Offsets stay right past the offsets Scan keeps for the longest label.
+	text := strings.Repeat("x\xffẞFoo", 50) + "STRAẞE"
+	got = nil
+	assert.Nil(t, m.Scan(strings.NewReader(text), func(k Match) bool {
+		got = append(got, k)
+		return true
+	}))
+	assert.Len(t, got, 51)
+	assert.Equal(t, m.FindAll(text), got)
+	assert.Equal(t, "STRAẞE", text[got[50].Offset:])
+
+	st := s.NewSharded(16)
+	assert.Nil(t, st.Add("Äpfel/rot", 1))
+	labels = nil
+	st.WalkPrefix("äPFEL", func(label string, n *Node) bool {
+		labels = append(labels, label)
+		return true
+	})
+	assert.Equal(t, []string{"äpfel/rot"}, labels)
+}
+
+func completions(cs []Completion) []string {
+	var labels []string
+	for _, c := range cs {
+		labels = append(labels, c.Label)
+	}
+	return labels
+}
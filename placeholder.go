@@ -0,0 +1,422 @@
+package radix
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// ErrConstraint indicates a placeholder constraint that can't be compiled.
+var ErrConstraint = errors.New("invalid placeholder constraint")
+
+// Syntax is the syntax of placeholders in labels.
+type Syntax uint8
+
+const (
+	// EscapeSyntax starts placeholders with the escape symbol, as in "@id",
+	// "@id{[0-9]+}", "@id:int", "@name.json" and "@month?".
+	EscapeSyntax Syntax = iota
+	// ColonSyntax is EscapeSyntax with a colon as the escape symbol, as in
+	// ":id", ":id:int" and ":month?", for routes written like Express's.
+	ColonSyntax
+	// BraceSyntax encloses placeholders in braces, as in "{id}", "{id:int}"
+	// or "{name}.json", for routes written like OpenAPI paths. As in Go's
+	// ServeMux patterns, only a placeholder ending in "...", as in
+	// "{path...}", matches the rest of a label, and it must end the label.
+	BraceSyntax
+)
+
+// placeholder is a placeholder of a label, such as "@id", which may be
+// constrained by a regular expression, as in "@id{[0-9]+}", or by a named
+// type, as in "@id:int". It may be followed by a literal suffix within
+// its segment, as in "@name.json", or, if it is the label's last segment,
+// be marked optional, as in "@month?".
+//
+// A doubled escape symbol, as in "/@@me", is a literal escape symbol rather
+// than a placeholder, and a regular expression may hold delimiters, as in
+// "@date{[0-9]+/[0-9]+}", in which case it matches across segments.
+type placeholder struct {
+	name       string
+	constraint string // "{regexp}" or ":type", or empty
+	optional   bool
+	rest       bool // whether it is a "{path...}" placeholder
+	spans      bool // whether its constraint holds a delimiter
+	suffix     string
+	head       int // index of the label right before the suffix
+	end        int // index of the label right after the suffix
+}
+
+// types are the named types placeholders can be constrained by.
+var types = map[string]func(string) bool{
+	"int":  isInt,
+	"uuid": isUUID,
+}
+
+// constraints holds compiled constraints by their text.
+var constraints sync.Map
+
+// placeholder parses the placeholder that starts at label[i].
+//
+// Its name is made of letters, digits and underscores, and its suffix
+// runs until the next delimiter, which must not hold another placeholder.
+//
+// A suffix right after the name must start with a dot or a literal escape
+// symbol, as names used to run until the delimiter, so that a label such as
+// "/users/@user-id" is rejected rather than read as the name "user"
+// followed by "-id". Dotted names, such as "@file.name", are read as
+// the name "file" followed by the suffix ".name".
+func (tr *Tree) placeholder(label string, i int) (placeholder, error) {
+	if tr.syntax == BraceSyntax {
+		return tr.bracePlaceholder(label, i)
+	}
+	var p placeholder
+	j := i + 1
+	for j < len(label) && isNameByte(label[j]) {
+		j++
+	}
+	p.name = label[i+1 : j]
+	start := j
+	if j < len(label) && label[j] == '{' {
+		// Braces may be nested, as in "{[0-9]{4}}".
+		depth := 0
+	braces:
+		for ; j < len(label); j++ {
+			switch label[j] {
+			case '\\':
+				j++
+			case '{':
+				depth++
+			case '}':
+				if depth--; depth == 0 {
+					break braces
+				}
+			}
+		}
+		if j >= len(label) {
+			return p, ErrInvalid
+		}
+		j++
+	} else if j < len(label) && label[j] == ':' {
+		for j++; j < len(label) && isNameByte(label[j]); j++ {
+		}
+	}
+	p.constraint = label[start:j]
+	if p.constraint == "" && j < len(label) && label[j] != tr.delim && label[j] != '?' && label[j] != '.' && !tr.literal(label, j) {
+		return p, ErrInvalid
+	}
+	p.spans = strings.IndexByte(p.constraint, tr.delim) >= 0
+	if j < len(label) && label[j] == '?' {
+		p.optional = true
+		j++
+	}
+	return tr.suffix(p, label, j)
+}
+
+// bracePlaceholder parses the placeholder that starts at label[i] in
+// BraceSyntax, which may only be constrained by a named type.
+func (tr *Tree) bracePlaceholder(label string, i int) (placeholder, error) {
+	var p placeholder
+	j := i + 1
+	for j < len(label) && isNameByte(label[j]) {
+		j++
+	}
+	p.name = label[i+1 : j]
+	start := j
+	if j < len(label) && label[j] == ':' {
+		for j++; j < len(label) && isNameByte(label[j]); j++ {
+		}
+	}
+	p.constraint = label[start:j]
+	if strings.HasPrefix(label[j:], "...") {
+		p.rest = true
+		j += len("...")
+	}
+	if p.name == "" || j >= len(label) || label[j] != '}' {
+		return p, ErrInvalid
+	}
+	return tr.suffix(p, label, j+1)
+}
+
+// suffix sets the suffix of a placeholder, which starts at label[i].
+func (tr *Tree) suffix(p placeholder, label string, i int) (placeholder, error) {
+	j := i
+	literal := false
+	for j < len(label) && label[j] != tr.delim {
+		if label[j] == tr.escape {
+			if !tr.literal(label, j) {
+				return p, ErrInvalid
+			}
+			literal = true
+			j++
+		}
+		j++
+	}
+	p.head = i
+	p.suffix = label[i:j]
+	if literal {
+		p.suffix = tr.unescape(p.suffix)
+	}
+	p.end = j
+	return p, nil
+}
+
+// literal reports whether label[i] starts a literal escape symbol.
+func (tr *Tree) literal(label string, i int) bool {
+	return i+1 < len(label) && label[i] == tr.escape && label[i+1] == tr.escape
+}
+
+// unescape replaces the literal escape symbols of s with single ones.
+func (tr *Tree) unescape(s string) string {
+	b := make([]byte, 0, len(s))
+	for i := 0; i < len(s); i++ {
+		if tr.literal(s, i) {
+			i++
+		}
+		b = append(b, s[i])
+	}
+	return string(b)
+}
+
+// multi reports whether the placeholder may match the whole rest of a label,
+// across delimiters, which any placeholder ending a label can, except in
+// BraceSyntax where only "{path...}" placeholders can.
+func (tr *Tree) multi(p placeholder) bool {
+	return tr.syntax != BraceSyntax || p.rest
+}
+
+func isNameByte(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || c == '_'
+}
+
+// matches reports whether the placeholder's constraint accepts v.
+func (p placeholder) matches(v string) bool {
+	if p.constraint == "" {
+		return true
+	}
+	check, err := compile(p.constraint)
+	return err == nil && check(v)
+}
+
+// compile compiles a constraint, caching it for later lookups.
+func compile(constraint string) (func(string) bool, error) {
+	if check, ok := constraints.Load(constraint); ok {
+		return check.(func(string) bool), nil
+	}
+	var check func(string) bool
+	if constraint[0] == ':' {
+		if check = types[constraint[1:]]; check == nil {
+			return nil, fmt.Errorf("%w: unknown type %q", ErrConstraint, constraint[1:])
+		}
+	} else {
+		re, err := regexp.Compile("^(?:" + constraint[1:len(constraint)-1] + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrConstraint, err)
+		}
+		check = re.MatchString
+	}
+	constraints.Store(constraint, check)
+	return check, nil
+}
+
+// isInt reports whether s is a decimal integer.
+func isInt(s string) bool {
+	if len(s) > 1 && s[0] == '-' {
+		s = s[1:]
+	}
+	for i := range s {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// isUUID reports whether s is a UUID in its canonical textual form.
+func isUUID(s string) bool {
+	if len(s) != 36 {
+		return false
+	}
+	for i := range s {
+		switch {
+		case i == 8 || i == 13 || i == 18 || i == 23:
+			if s[i] != '-' {
+				return false
+			}
+		case '0' <= s[i] && s[i] <= '9', 'a' <= s[i] && s[i] <= 'f', 'A' <= s[i] && s[i] <= 'F':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// validate checks whether a label's placeholders are well formed, with at
+// most one placeholder between delimiters and optional placeholders only as
+// the last segment, and compiles their constraints.
+func (tr *Tree) validate(label string) error {
+	for i := 0; i < len(label); i++ {
+		if label[i] != tr.escape {
+			continue
+		}
+		if tr.literal(label, i) {
+			i++
+			continue
+		}
+		p, err := tr.placeholder(label, i)
+		if err != nil {
+			return err
+		}
+		if p.optional && (p.suffix != "" || p.end < len(label) || i == 0 || label[i-1] != tr.delim) {
+			return ErrInvalid
+		}
+		if p.rest && (p.suffix != "" || p.end < len(label)) {
+			return ErrInvalid
+		}
+		if p.constraint != "" {
+			if _, err := compile(p.constraint); err != nil {
+				return err
+			}
+		}
+		i = p.end - 1
+	}
+	return nil
+}
+
+// common returns the length of the longest common prefix of two labels
+// that doesn't end in the middle of a placeholder or, with Trunes, a rune.
+func (tr *Tree) common(a, b string) int {
+	i := 0
+	for i < len(a) && i < len(b) && a[i] == b[i] {
+		if a[i] != tr.escape {
+			i++
+			continue
+		}
+		if la, lb := tr.literal(a, i), tr.literal(b, i); la || lb {
+			if !la || !lb {
+				return i
+			}
+			i += 2
+			continue
+		}
+		pa, errA := tr.placeholder(a, i)
+		pb, errB := tr.placeholder(b, i)
+		if errA != nil || errB != nil || pa.end != pb.end || a[i:pa.end] != b[i:pb.end] {
+			return i
+		}
+		i = pa.end
+	}
+	if tr.runes {
+		for i > 0 && (i < len(a) && !utf8.RuneStart(a[i]) || i < len(b) && !utf8.RuneStart(b[i])) {
+			i--
+		}
+	}
+	return i
+}
+
+// endsInPlaceholder reports whether a label ends with a placeholder.
+func (tr *Tree) endsInPlaceholder(label string) bool {
+	end := -1
+	for i := 0; i < len(label); i++ {
+		if tr.literal(label, i) {
+			i++
+		} else if label[i] == tr.escape {
+			p, err := tr.placeholder(label, i)
+			if err != nil {
+				return false
+			}
+			end = p.end
+			i = p.end - 1
+		}
+	}
+	return end == len(label)
+}
+
+// ambiguous reports whether two different placeholders can't be told apart,
+// which is the case when they have the same constraint and suffix and both
+// do or don't match the rest of a label, or when they are both optional,
+// as both would match labels omitting them.
+func (tr *Tree) ambiguous(a, b string) bool {
+	if tr.literal(a, 0) || tr.literal(b, 0) {
+		return false
+	}
+	pa, _ := tr.placeholder(a, 0)
+	pb, _ := tr.placeholder(b, 0)
+	if tr.isOptional(a) && tr.isOptional(b) {
+		return true
+	}
+	return pa.constraint == pb.constraint && pa.suffix == pb.suffix && pa.rest == pb.rest
+}
+
+// isOptional reports whether s is made of an optional placeholder only.
+func (tr *Tree) isOptional(s string) bool {
+	if s == "" || s[0] != tr.escape || tr.literal(s, 0) {
+		return false
+	}
+	p, err := tr.placeholder(s, 0)
+	return err == nil && p.optional && p.end == len(s)
+}
+
+// optional returns the node holding a value that is reached by following
+// rest, the unmatched part of the edge's label leading to n, and then its
+// edges, with only a delimiter and an optional placeholder, if any, along
+// with its depth below n.
+//
+// This lets a lookup omit a label's optional last segment.
+func (tr *Tree) optional(n *Node, rest string) (*Node, int) {
+	depth := 0
+	if rest == "" {
+		e := n.child(tr.delim)
+		if e == nil {
+			return nil, 0
+		}
+		n, rest = e.node, e.label
+		depth++
+	}
+	if rest[0] != tr.delim {
+		return nil, 0
+	}
+	if rest = rest[1:]; rest != "" {
+		if tr.isOptional(rest) && n.Value != nil {
+			return n, depth
+		}
+		return nil, 0
+	}
+	for e := n.child(tr.escape); e != nil; e = n.nextChild(e) {
+		if tr.isOptional(e.label) && e.node.Value != nil {
+			return e.node, depth + 1
+		}
+	}
+	return nil, 0
+}
+
+// childFor returns the edge of n that leads to the label, that is, the one
+// starting with the same byte or, for placeholders, the same placeholder
+// and, with Trunes, the same rune.
+func (tr *Tree) childFor(n *Node, label string) *edge {
+	e := n.child(label[0])
+	if e == nil || label[0] != tr.escape && !n.shared && !tr.runes {
+		return e
+	}
+	for ; e != nil; e = n.nextChild(e) {
+		if tr.common(e.label, label) > 0 {
+			return e
+		}
+	}
+	return nil
+}
+
+// mismatch explains why a placeholder doesn't match a segment.
+func (tr *Tree) mismatch(p placeholder, segment string) string {
+	switch {
+	case segment == "":
+		return "empty segment"
+	case !strings.HasSuffix(segment, p.suffix):
+		return fmt.Sprintf("segment %q doesn't end with %q", segment, p.suffix)
+	case len(segment) == len(p.suffix):
+		return fmt.Sprintf("segment %q has no value before %q", segment, p.suffix)
+	}
+	return fmt.Sprintf("%q doesn't match the constraint %s", segment[:len(segment)-len(p.suffix)], p.constraint)
+}
@@ -0,0 +1,425 @@
+package radix_test
+
+import (
+	"errors"
+	"sort"
+	"testing"
+
+	. "github.com/knnat/radix"
+	"github.com/stretchr/testify/assert"
+)
+
+var constrainedLabels = []string{
+	"/posts/@id:int",
+	"/posts/@id:int/edit",
+	"/posts/@slug",
+	"/users/@id{[0-9]+}/posts",
+	"/users/@name/likes",
+	"/users/new/edit",
+	"/users/@name/show",
+	"/files/@key:uuid",
+	"/years/@year{[0-9]{4}}",
+}
+
+func TestConstraints(t *testing.T) {
+	build := func(labels []string) *Tree {
+		b := NewBuilder(len(labels))
+		for _, l := range labels {
+			assert.Nil(t, b.Add(l, l))
+		}
+		return b.Tree()
+	}
+	sorted := []string{
+		"/files/@key:uuid", "/posts/@id:int", "/posts/@id:int/edit", "/posts/@slug",
+		"/users/@id{[0-9]+}/posts", "/users/@name/likes", "/users/@name/show",
+		"/users/new/edit", "/years/@year{[0-9]{4}}",
+	}
+	for _, tr := range []*Tree{New(), (&Settings{Flags: Tatomic, Escape: '@', Delimiter: '/'}).New(), build(sorted)} {
+		if tr.Len() == 1 {
+			for _, l := range constrainedLabels {
+				assert.Nil(t, tr.Add(l, l))
+			}
+		}
+		for _, tc := range []struct {
+			label  string
+			want   interface{}
+			params map[string]string
+		}{
+			{"/posts/12", "/posts/@id:int", map[string]string{"id": "12"}},
+			{"/posts/-12/edit", "/posts/@id:int/edit", map[string]string{"id": "-12"}},
+			{"/posts/hello", "/posts/@slug", map[string]string{"slug": "hello"}},
+			{"/posts/hello/edit", "/posts/@slug", map[string]string{"slug": "hello/edit"}},
+			{"/users/12/posts", "/users/@id{[0-9]+}/posts", map[string]string{"id": "12"}},
+			// Constraints fall through to the other placeholders.
+			{"/users/12/likes", "/users/@name/likes", map[string]string{"name": "12"}},
+			{"/users/bob/likes", "/users/@name/likes", map[string]string{"name": "bob"}},
+			{"/users/bob/show", "/users/@name/show", map[string]string{"name": "bob"}},
+			// Static edges fall through to placeholders.
+			{"/users/new/edit", "/users/new/edit", nil},
+			{"/users/new/show", "/users/@name/show", map[string]string{"name": "new"}},
+			{"/users/bob/posts", nil, nil},
+			{"/files/123e4567-e89b-12d3-a456-426614174000", "/files/@key:uuid", map[string]string{"key": "123e4567-e89b-12d3-a456-426614174000"}},
+			{"/files/123e4567", nil, nil},
+			{"/years/2019", "/years/@year{[0-9]{4}}", map[string]string{"year": "2019"}},
+			{"/years/201", nil, nil},
+		} {
+			n, p := tr.Get(tc.label)
+			if tc.want == nil {
+				assert.Nil(t, n, tc.label)
+				continue
+			}
+			if assert.NotNil(t, n, tc.label) {
+				assert.Equal(t, tc.want, n.Value, tc.label)
+				assert.Equal(t, tc.params, p, tc.label)
+			}
+		}
+		var walked []string
+		tr.Walk(func(label string, n *Node) bool {
+			assert.Equal(t, label, n.Value)
+			walked = append(walked, label)
+			return true
+		})
+		assert.Equal(t, sorted, walked)
+	}
+}
+
+func TestConstraintErrors(t *testing.T) {
+	tr := New()
+	assert.Equal(t, ErrInvalid, tr.Add("/@id{[0-9]+", 1))
+	assert.Equal(t, ErrInvalid, tr.Add("/@id{[0-9]+}x@y", 1))
+	assert.Equal(t, ErrInvalid, tr.Add("/@id:int@x", 1))
+	assert.True(t, errors.Is(tr.Add("/@id:float", 1), ErrConstraint))
+	assert.True(t, errors.Is(tr.Add("/@id{(}", 1), ErrConstraint))
+
+	b := NewBuilder(0)
+	assert.True(t, errors.Is(b.Add("/@id:float", 1), ErrConstraint))
+
+	// Placeholders sharing a node must have different constraints.
+	for _, tr := range []*Tree{New(), nil} {
+		add := func(label string) error { return tr.Add(label, label) }
+		if tr == nil {
+			b := NewBuilder(0)
+			add = func(label string) error { return b.Add(label, label) }
+		}
+		assert.Nil(t, add("/a/@id{[0-9]+}"))
+		assert.Nil(t, add("/a/@id{[0-9]+}/x"))
+		assert.Nil(t, add("/a/@id{[a-z]+}"))
+		assert.Equal(t, ErrEscape, add("/a/@num{[a-z]+}"))
+		assert.Nil(t, add("/a/@name"))
+		assert.Equal(t, ErrEscape, add("/a/@other/x"))
+		assert.Nil(t, add("/b/@id:int"))
+		assert.Equal(t, ErrEscape, add("/b/@id:int/"))
+	}
+}
+
+func TestConstraintsDel(t *testing.T) {
+	tr := New()
+	for _, l := range constrainedLabels {
+		assert.Nil(t, tr.Add(l, l))
+	}
+	tr.Del("/posts/@id:int")
+	tr.Del("/users/@id{[0-9]+}/posts")
+	n, p := tr.Get("/posts/12")
+	assert.Equal(t, "/posts/@slug", n.Value)
+	assert.Equal(t, map[string]string{"slug": "12"}, p)
+	n, _ = tr.Get("/posts/12/edit")
+	assert.Equal(t, "/posts/@id:int/edit", n.Value)
+	n, p = tr.Get("/users/12/likes")
+	assert.Equal(t, "/users/@name/likes", n.Value)
+	assert.Equal(t, map[string]string{"name": "12"}, p)
+	n, _ = tr.Get("/users/12/posts")
+	assert.Nil(t, n)
+
+	st := NewSharded(4)
+	for _, l := range []string{"@id:int", "@name", "abc"} {
+		assert.Nil(t, st.Add(l, l))
+	}
+	n, p = st.Get("12")
+	assert.Equal(t, "@id:int", n.Value)
+	assert.Equal(t, map[string]string{"id": "12"}, p)
+	n, _ = st.Get("abc")
+	assert.Equal(t, "abc", n.Value)
+	n, p = st.Get("abd")
+	assert.Equal(t, "@name", n.Value)
+	assert.Equal(t, map[string]string{"name": "abd"}, p)
+
+	// Malformed placeholders in literal lookups are compared as they are.
+	assert.Nil(t, tr.PrefixesOf("x@@{"))
+	assert.Nil(t, tr.PrefixesOf("/posts/@i{"))
+	assert.Nil(t, tr.PrefixesOf("/posts/@id:int@"))
+	assert.Equal(t, 1, len(tr.PrefixesOf("/posts/@id:int/edit")))
+}
+
+func TestSuffixes(t *testing.T) {
+	tr := New()
+	for _, l := range []string{"/files/@name.json", "/files/@name.xml", "/files/@id:int.json", "/v@version/docs"} {
+		assert.Nil(t, tr.Add(l, l))
+	}
+	assert.Equal(t, ErrEscape, tr.Add("/files/@other.json", 0))
+	assert.Equal(t, ErrInvalid, tr.Add("/files/@name.@ext", 0))
+	// Names used to run until the delimiter, so other suffixes are
+	// rejected rather than silently read as a shorter name.
+	for _, l := range []string{"/users/@user-id", "/users/@id~x", "/users/@id@x"} {
+		assert.Equal(t, ErrInvalid, tr.Add(l, 0), l)
+	}
+	assert.Nil(t, tr.Add("/users/@id{[0-9]+}-x", 0))
+	colon := (&Settings{Syntax: ColonSyntax, Delimiter: '/'}).New()
+	assert.Equal(t, ErrInvalid, colon.Add("/users/:user-id", 0))
+	assert.Nil(t, colon.Add("/users/:id.json", 0))
+	// Dotted names are read as a name followed by a suffix.
+	dotted := New()
+	assert.Nil(t, dotted.Add("/@file.name", 1))
+	n, p := dotted.Get("/report.name")
+	if assert.NotNil(t, n) {
+		assert.Equal(t, map[string]string{"file": "report"}, p)
+	}
+	n, _ = dotted.Get("/report.txt")
+	assert.Nil(t, n)
+	for _, tc := range []struct {
+		label  string
+		want   interface{}
+		params map[string]string
+	}{
+		{"/files/report.json", "/files/@name.json", map[string]string{"name": "report"}},
+		{"/files/12.json", "/files/@id:int.json", map[string]string{"id": "12"}},
+		{"/files/a.b.xml", "/files/@name.xml", map[string]string{"name": "a.b"}},
+		{"/files/.json", nil, nil},
+		{"/files/report.txt", nil, nil},
+		{"/v2/docs", "/v@version/docs", map[string]string{"version": "2"}},
+	} {
+		n, p := tr.Get(tc.label)
+		if tc.want == nil {
+			assert.Nil(t, n, tc.label)
+			continue
+		}
+		if assert.NotNil(t, n, tc.label) {
+			assert.Equal(t, tc.want, n.Value, tc.label)
+			assert.Equal(t, tc.params, p, tc.label)
+		}
+	}
+}
+
+func TestSuffixPrecedence(t *testing.T) {
+	// Placeholders with a suffix are tried first, longest suffixes
+	// first, whatever the placeholders are named.
+	for _, names := range [][3]string{{"file", "name", "arc"}, {"path", "a", "z"}, {"a", "z", "m"}} {
+		tr := New()
+		labels := []string{"/files/@" + names[0], "/files/@" + names[1] + ".json", "/files/@" + names[2] + ".tar.gz", "/files/@" + names[1] + ".gz"}
+		for i, l := range labels {
+			assert.Nil(t, tr.Add(l, i))
+		}
+		for label, want := range map[string]int{
+			"/files/x":        0,
+			"/files/x.json":   1,
+			"/files/x.tar.gz": 2,
+			"/files/x.gz":     3,
+		} {
+			n, _ := tr.Get(label)
+			if assert.NotNil(t, n, label) {
+				assert.Equal(t, want, n.Value, "%s with %v", label, names)
+			}
+		}
+	}
+}
+
+func TestOptional(t *testing.T) {
+	for _, labels := range [][]string{
+		{"/archive/@year/@month?"},
+		{"/archive/@year/@month?", "/archive/@year/x"},
+		{"/archive/@year/x", "/archive/@year/@month?", "/archive/@year/y/z"},
+	} {
+		tr := New()
+		for _, l := range labels {
+			assert.Nil(t, tr.Add(l, l))
+		}
+		n, p := tr.Get("/archive/2019")
+		if assert.NotNil(t, n, labels) {
+			assert.Equal(t, "/archive/@year/@month?", n.Value)
+			assert.Equal(t, map[string]string{"year": "2019"}, p)
+		}
+		n, p = tr.Get("/archive/2019/05")
+		if assert.NotNil(t, n, labels) {
+			assert.Equal(t, "/archive/@year/@month?", n.Value)
+			assert.Equal(t, map[string]string{"year": "2019", "month": "05"}, p)
+		}
+		n, _ = tr.Get("/archive/2019/")
+		assert.Nil(t, n, labels)
+		n, _ = tr.Get("/archive")
+		assert.Nil(t, n, labels)
+
+		assert.Equal(t, ErrEscape, tr.Add("/archive/@year", 0))
+		assert.Equal(t, ErrEscape, tr.Add("/archive/@year/@month", 0))
+		assert.Equal(t, ErrEscape, tr.Add("/archive/@year/@day:int?", 0))
+		assert.Nil(t, tr.Set("/archive/@year/@month?", 1))
+	}
+
+	tr := New()
+	assert.Nil(t, tr.Add("/blog/@slug", 1))
+	assert.Equal(t, ErrEscape, tr.Add("/blog/@slug/@page?", 2))
+	for _, l := range []string{"/@a?/b", "/@a?x", "@a?", "x@a?"} {
+		assert.Equal(t, ErrInvalid, tr.Add(l, 0), l)
+	}
+
+	b := NewBuilder(0)
+	assert.Nil(t, b.Add("/a/@x?", 1))
+	assert.Equal(t, ErrEscape, b.Add("/a/@y:int?", 2))
+	assert.Nil(t, b.Add("/b", 3))
+	assert.Equal(t, ErrEscape, b.Add("/b/@page?", 4))
+	tr = b.Tree()
+	n, p := tr.Get("/a")
+	assert.Equal(t, 1, n.Value)
+	assert.Nil(t, p)
+	n, p = tr.Get("/a/1")
+	assert.Equal(t, 1, n.Value)
+	assert.Equal(t, map[string]string{"x": "1"}, p)
+}
+
+func TestSyntaxes(t *testing.T) {
+	colon := &Settings{Delimiter: '/', Syntax: ColonSyntax}
+	brace := &Settings{Delimiter: '/', Syntax: BraceSyntax}
+	for _, tc := range []struct {
+		s      *Settings
+		labels []string
+		label  string
+		want   interface{}
+		params map[string]string
+	}{
+		{colon, []string{"/users/:id", "/users/:id/posts"}, "/users/1/posts", "/users/:id/posts", map[string]string{"id": "1"}},
+		{colon, []string{"/users/:id:int", "/users/:name"}, "/users/bob", "/users/:name", map[string]string{"name": "bob"}},
+		{colon, []string{"/archive/:year/:month?"}, "/archive/2019", "/archive/:year/:month?", map[string]string{"year": "2019"}},
+		// Escape symbols are only special in EscapeSyntax.
+		{colon, []string{"/@me", "/:id"}, "/@me", "/@me", nil},
+		{brace, []string{"/users/{id}", "/users/{id}/posts"}, "/users/1/posts", "/users/{id}/posts", map[string]string{"id": "1"}},
+		{brace, []string{"/users/{id:int}", "/users/{name}"}, "/users/12", "/users/{id:int}", map[string]string{"id": "12"}},
+		{brace, []string{"/reports/{name}.json"}, "/reports/q1.json", "/reports/{name}.json", map[string]string{"name": "q1"}},
+		{brace, []string{"/files/{path...}"}, "/files/a/b.txt", "/files/{path...}", map[string]string{"path": "a/b.txt"}},
+		{brace, []string{"/files/{path...}"}, "/files/b.txt", "/files/{path...}", map[string]string{"path": "b.txt"}},
+		// Only "{path...}" placeholders match several segments,
+		// and they are tried after the other placeholders.
+		{brace, []string{"/users/{id}"}, "/users/1/posts", nil, nil},
+		{brace, []string{"/x/{a...}", "/x/{z}"}, "/x/1", "/x/{z}", map[string]string{"z": "1"}},
+		{brace, []string{"/x/{a...}", "/x/{z}"}, "/x/1/2", "/x/{a...}", map[string]string{"a": "1/2"}},
+	} {
+		tr := tc.s.New()
+		b := tc.s.NewBuilder(len(tc.labels))
+		for _, l := range tc.labels {
+			assert.Nil(t, tr.Add(l, l), l)
+		}
+		sort.Strings(tc.labels)
+		for _, l := range tc.labels {
+			assert.Nil(t, b.Add(l, l), l)
+		}
+		for _, tr := range []*Tree{tr, b.Tree()} {
+			n, p := tr.Get(tc.label)
+			if tc.want == nil {
+				assert.Nil(t, n, tc.label)
+				continue
+			}
+			if assert.NotNil(t, n, tc.label) {
+				assert.Equal(t, tc.want, n.Value, tc.label)
+				assert.Equal(t, tc.params, p, tc.label)
+			}
+		}
+	}
+
+	tr := brace.New()
+	for _, l := range []string{"/{}", "/{id", "/{id...}/x", "/{id...}.json", "/{a}{b}", "/{id{[0-9]+}}"} {
+		assert.Equal(t, ErrInvalid, tr.Add(l, 0), l)
+	}
+	assert.Nil(t, tr.Add("/{id}", 1))
+	assert.Equal(t, ErrEscape, tr.Add("/{name}", 2))
+	assert.Nil(t, tr.Add("/{path...}", 3))
+	tr.Del("/{id}")
+	n, p := tr.Get("/1")
+	if assert.NotNil(t, n) {
+		assert.Equal(t, 3, n.Value)
+		assert.Equal(t, map[string]string{"path": "1"}, p)
+	}
+
+	st := brace.NewSharded(4)
+	assert.Nil(t, st.Add("/users/{id}", 1))
+	n, p = st.Get("/users/1")
+	if assert.NotNil(t, n) {
+		assert.Equal(t, map[string]string{"id": "1"}, p)
+	}
+}
+
+func TestLiterals(t *testing.T) {
+	labels := []string{
+		"/@@me",
+		"/@@me/@@@tab",
+		"/@id",
+		"/at/@id@@host",
+		"/dates/@date{[0-9]+/[0-9]+}",
+		"/dates/@date{[0-9]+/[0-9]+}/show",
+		"/dates/@slug",
+		"/mail/@user@@example.com",
+	}
+	b := NewBuilder(len(labels))
+	for _, l := range labels {
+		assert.Nil(t, b.Add(l, l), l)
+	}
+	tr := New()
+	for i := len(labels) - 1; i >= 0; i-- {
+		assert.Nil(t, tr.Add(labels[i], labels[i]), labels[i])
+	}
+	for _, tr := range []*Tree{tr, b.Tree()} {
+		for _, tc := range []struct {
+			label  string
+			want   interface{}
+			params map[string]string
+		}{
+			{"/@me", "/@@me", nil},
+			{"/@me/@bob", "/@@me/@@@tab", map[string]string{"tab": "bob"}},
+			{"/me", "/@id", map[string]string{"id": "me"}},
+			{"/at/bob@host", "/at/@id@@host", map[string]string{"id": "bob"}},
+			{"/dates/2019/05", "/dates/@date{[0-9]+/[0-9]+}", map[string]string{"date": "2019/05"}},
+			{"/dates/2019/05/show", "/dates/@date{[0-9]+/[0-9]+}/show", map[string]string{"date": "2019/05"}},
+			{"/dates/2019", "/dates/@slug", map[string]string{"slug": "2019"}},
+			{"/mail/bob@example.com", "/mail/@user@@example.com", map[string]string{"user": "bob"}},
+			// Escape symbols in looked up labels are always literal.
+			{"/@@me", "/@id", map[string]string{"id": "@@me"}},
+		} {
+			n, p := tr.Get(tc.label)
+			if tc.want == nil {
+				assert.Nil(t, n, tc.label)
+				continue
+			}
+			if assert.NotNil(t, n, tc.label) {
+				assert.Equal(t, tc.want, n.Value, tc.label)
+				assert.Equal(t, tc.params, p, tc.label)
+			}
+		}
+		var walked []string
+		tr.Walk(func(label string, n *Node) bool {
+			walked = append(walked, label)
+			return true
+		})
+		assert.Equal(t, labels, walked)
+		assert.Contains(t, tr.String(), "@@me")
+	}
+
+	assert.Nil(t, tr.Add("/@@", 1))
+	n, _ := tr.Get("/@")
+	if assert.NotNil(t, n) {
+		assert.Equal(t, 1, n.Value)
+	}
+	tr.Del("/@@me")
+	n, p := tr.Get("/@me")
+	if assert.NotNil(t, n) {
+		assert.Equal(t, map[string]string{"id": "@me"}, p)
+	}
+	n, p = tr.Get("/@me/@bob")
+	if assert.NotNil(t, n) {
+		assert.Equal(t, map[string]string{"tab": "bob"}, p)
+	}
+
+	tr = (&Settings{Delimiter: '/', Syntax: BraceSyntax}).New()
+	assert.Nil(t, tr.Add("/{{id}", 1))
+	assert.Nil(t, tr.Add("/{id}", 2))
+	n, p = tr.Get("/{id}")
+	if assert.NotNil(t, n) {
+		assert.Equal(t, 1, n.Value)
+		assert.Nil(t, p)
+	}
+}
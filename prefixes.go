@@ -0,0 +1,78 @@
+package radix
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// Entry is a label held by a tree.
+type Entry struct {
+	Label string
+	Node  *Node
+}
+
+// PrefixesOf returns all labels that are prefixes of s,
+// including s itself, from the shortest to the longest.
+//
+// Placeholders are not expanded, labels are compared as they were added.
+func (tr *Tree) PrefixesOf(s string) []Entry {
+	var entries []Entry
+	tr.WalkPrefixesOf(s, func(label string, n *Node) bool {
+		entries = append(entries, Entry{Label: label, Node: n})
+		return true
+	})
+	return entries
+}
+
+// WalkPrefixesOf calls fn for every node holding a value whose label is a
+// prefix of s, from the shortest to the longest, until fn returns false.
+//
+// Labels passed to fn are slices of s, as it was given if the tree has
+// a key normalizer, so the walk doesn't allocate unless it has one.
+// The tree must not be modified by fn.
+func (tr *Tree) WalkPrefixesOf(s string, fn func(label string, n *Node) bool) {
+	defer tr.runlock()
+	sr := tr.newSearch(s)
+	tr.walkPrefixesOf(tr.rlock().root, sr.key, 0, func(end int, n *Node) bool {
+		return fn(s[:sr.offset(end)], n)
+	})
+}
+
+// walkPrefixesOf is WalkPrefixesOf for key from index start, below the
+// root of a version of the tree, passing fn the index each label ends at.
+func (tr *Tree) walkPrefixesOf(root *Node, key string, start int, fn func(end int, n *Node) bool) {
+	tnode := root
+	depth := 0
+	for i := start; i < len(key); {
+		e := tr.childFor(tnode, key[i:])
+		if e == nil || !strings.HasPrefix(key[i:], e.label) {
+			return
+		}
+		i += len(e.label)
+		tnode = e.node
+		depth++
+		if tr.runes && i < len(key) && !utf8.RuneStart(key[i]) {
+			continue
+		}
+		if tnode.Value != nil && !fn(i, tnode.at(depth)) {
+			return
+		}
+	}
+}
+
+// LongestPrefix returns the longest label that is a prefix of s,
+// along with its node, and reports whether there is one.
+//
+// If the tree has a key normalizer, s is looked up as it maps it,
+// and the label returned is the part of s, as it was given, found.
+func (tr *Tree) LongestPrefix(s string) (string, *Node, bool) {
+	var (
+		label string
+		node  *Node
+	)
+	tr.WalkPrefixesOf(s, func(l string, n *Node) bool {
+		label, node = l, n
+		return true
+	})
+	return label, node, node != nil
+}
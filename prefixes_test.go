@@ -0,0 +1,57 @@
+package radix_test
+
+import (
+	"testing"
+
+	. "github.com/knnat/radix"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrefixesOf(t *testing.T) {
+	for _, flags := range []int{0, Tatomic} {
+		tr := (&Settings{Flags: flags, Escape: '@', Delimiter: '/'}).New()
+		for _, l := range []string{"/", "/usr", "/usr/local", "/usr/local/bin", "/usr/lib", "/var"} {
+			assert.Nil(t, tr.Add(l, l))
+		}
+		tr.Del("/usr/local")
+
+		labels := func(entries []Entry) []string {
+			var labels []string
+			for _, e := range entries {
+				assert.Equal(t, e.Label, e.Node.Value)
+				labels = append(labels, e.Label)
+			}
+			return labels
+		}
+		assert.Equal(t, []string{"/", "/usr", "/usr/local/bin"}, labels(tr.PrefixesOf("/usr/local/bin/go")))
+		assert.Equal(t, []string{"/", "/usr"}, labels(tr.PrefixesOf("/usr/loc")))
+		assert.Equal(t, []string{"/", "/usr", "/usr/lib"}, labels(tr.PrefixesOf("/usr/lib")))
+		assert.Equal(t, []string{"/"}, labels(tr.PrefixesOf("/va")))
+		assert.Nil(t, tr.PrefixesOf("usr"))
+		assert.Nil(t, tr.PrefixesOf(""))
+
+		var walked []string
+		tr.WalkPrefixesOf("/usr/local/bin", func(label string, n *Node) bool {
+			walked = append(walked, label)
+			return len(walked) < 2
+		})
+		assert.Equal(t, []string{"/", "/usr"}, walked)
+	}
+}
+
+func TestWalkPrefixesOfAllocs(t *testing.T) {
+	tr := New()
+	for _, l := range []string{"a", "ab", "abc", "abcd"} {
+		assert.Nil(t, tr.Add(l, l))
+	}
+	var n int
+	fn := func(string, *Node) bool {
+		n++
+		return true
+	}
+	allocs := testing.AllocsPerRun(100, func() {
+		tr.WalkPrefixesOf("abcdef", fn)
+	})
+	assert.Equal(t, 0.0, allocs)
+	assert.Equal(t, 4*101, n)
+}
@@ -0,0 +1,32 @@
+package radix
+
+import (
+	"strings"
+
+	"github.com/gbrlsnchs/color"
+)
+
+type printer struct {
+	*strings.Builder
+	colors [4]color.Color
+	debug  bool
+}
+
+// print returns a string representation of a tree structure.
+func (bd *printer) print(root *Node, length int) string {
+	p := *bd // printers share their colors, but not their buffers
+	bd = &p
+	bd.Builder = &strings.Builder{}
+	bd.WriteString(bd.colors[colorBold].Wrap("\n."))
+	if bd.debug {
+		mag := bd.colors[colorMagenta]
+		bd.WriteString(mag.Wrapf(" (%d node", length))
+		if length != 1 {
+			bd.WriteString(mag.Wrap("s")) // avoid writing "1 nodes"
+		}
+		bd.WriteString(mag.Wrap(")"))
+	}
+	bd.WriteByte('\n')
+	root.writeTo(bd)
+	return bd.String()
+}
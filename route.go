@@ -0,0 +1,135 @@
+package radix
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+var (
+	// ErrParams indicates parameters that can't fill a pattern's placeholders.
+	ErrParams = errors.New("invalid placeholder parameters")
+
+	// ErrName indicates a name already registered for another
+	// label, or one that isn't registered when building a label.
+	ErrName = errors.New("name already registered")
+)
+
+// AddNamed adds a new node to the tree, like Add, and registers
+// its label under a name, so that Build can be given the name.
+//
+// Unlike Add, it returns ErrInvalid for an empty label or a nil value,
+// so that names are only registered for labels that are added.
+// Names stay registered when their labels are deleted.
+func (tr *Tree) AddNamed(name, label string, v interface{}) error {
+	if label == "" || v == nil {
+		return ErrInvalid
+	}
+	// The name is checked and registered under the same lock as the
+	// label is added, so that a label is never added for a name taken.
+	if tr.safe {
+		defer tr.mu.Unlock()
+		tr.mu.Lock()
+	}
+	if l, ok := tr.names.Load(name); ok && l.(string) != label {
+		return ErrName
+	}
+	if tr.atomic {
+		defer tr.publish()
+	}
+	if _, err := tr.add(label, v, false); err != nil {
+		return err
+	}
+	tr.names.Store(name, label)
+	return nil
+}
+
+// Pattern returns the label registered under a name.
+func (tr *Tree) Pattern(name string) (string, bool) {
+	l, ok := tr.names.Load(name)
+	if !ok {
+		return "", false
+	}
+	return l.(string), true
+}
+
+// Build returns the label that the label registered under the given name
+// leads to once its placeholders are filled with params, as BuildPattern
+// does. Names that aren't registered are errors wrapping ErrName.
+//
+// Example:
+//
+//	tr.AddNamed("post", "/users/@id:int/posts/@pid", v)
+//	tr.Build("post", map[string]string{"id": "1", "pid": "2"}) // "/users/1/posts/2"
+func (tr *Tree) Build(name string, params map[string]string) (string, error) {
+	l, ok := tr.names.Load(name)
+	if !ok {
+		return "", fmt.Errorf("%w: %q isn't registered", ErrName, name)
+	}
+	return tr.BuildPattern(l.(string), params)
+}
+
+// BuildPattern returns the label a pattern leads to
+// once its placeholders are filled with params.
+//
+// Every placeholder needs a parameter, except for optional ones, whose segment
+// is left out when they have none, and parameters must match the constraints
+// of their placeholders. Parameters only hold delimiters for placeholders
+// that can match them, and parameters without a placeholder are errors.
+// Doubled escape symbols are built as single ones.
+//
+// Example:
+//
+//	tr.BuildPattern("/users/@id:int", map[string]string{"id": "1"}) // "/users/1"
+func (tr *Tree) BuildPattern(pattern string, params map[string]string) (string, error) {
+	if err := tr.validate(pattern); err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	known := make(map[string]bool)
+	for i := 0; i < len(pattern); i++ {
+		if pattern[i] != tr.escape {
+			b.WriteByte(pattern[i])
+			continue
+		}
+		if tr.literal(pattern, i) {
+			b.WriteByte(tr.escape)
+			i++
+			continue
+		}
+		p, _ := tr.placeholder(pattern, i)
+		known[p.name] = true
+		v, ok := params[p.name]
+		switch {
+		case !ok && p.optional:
+			// Leave the delimiter before the segment out as well.
+			s := b.String()
+			b.Reset()
+			b.WriteString(s[:len(s)-1])
+		case !ok:
+			return "", fmt.Errorf("%w: missing %q", ErrParams, p.name)
+		case v == "":
+			return "", fmt.Errorf("%w: empty %q", ErrParams, p.name)
+		case strings.IndexByte(v, tr.delim) >= 0 && !p.spans && (p.end < len(pattern) || !tr.multi(p)):
+			return "", fmt.Errorf("%w: %q holds a delimiter", ErrParams, p.name)
+		case !p.matches(v):
+			return "", fmt.Errorf("%w: %q doesn't match %s", ErrParams, p.name, p.constraint)
+		default:
+			b.WriteString(v)
+		}
+		b.WriteString(p.suffix)
+		i = p.end - 1
+	}
+	var unknown []string
+	for k := range params {
+		if !known[k] {
+			unknown = append(unknown, k)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return "", fmt.Errorf("%w: unknown %q", ErrParams, unknown[0])
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,109 @@
+package radix_test
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	. "github.com/knnat/radix"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuild(t *testing.T) {
+	tr := New()
+	assert.Nil(t, tr.AddNamed("post", "/users/@id:int/posts/@pid", 1))
+	assert.Nil(t, tr.AddNamed("archive", "/archive/@year{[0-9]{4}}/@month?", 2))
+	assert.Nil(t, tr.AddNamed("file", "/files/@path", 3))
+	assert.Nil(t, tr.AddNamed("me", "/@@me/@name.json", 4))
+	assert.Equal(t, ErrName, tr.AddNamed("post", "/posts/@pid", 5))
+	assert.Nil(t, tr.Add("/static", 6))
+	// Names are only registered for labels that are added.
+	assert.Equal(t, ErrInvalid, tr.AddNamed("empty", "", 7))
+	assert.Equal(t, ErrInvalid, tr.AddNamed("nil", "/nil", nil))
+	_, ok := tr.Pattern("empty")
+	assert.False(t, ok)
+	_, ok = tr.Pattern("nil")
+	assert.False(t, ok)
+	l, ok := tr.Pattern("post")
+	assert.True(t, ok)
+	assert.Equal(t, "/users/@id:int/posts/@pid", l)
+
+	for _, tc := range []struct {
+		pattern string
+		params  map[string]string
+		want    string
+		err     error
+	}{
+		{"post", map[string]string{"id": "1", "pid": "2"}, "/users/1/posts/2", nil},
+		{"/users/@id:int/posts/@pid", map[string]string{"id": "1", "pid": "2"}, "/users/1/posts/2", nil},
+		{"/static", nil, "/static", nil},
+		{"archive", map[string]string{"year": "2019", "month": "05"}, "/archive/2019/05", nil},
+		{"archive", map[string]string{"year": "2019"}, "/archive/2019", nil},
+		{"file", map[string]string{"path": "a/b.txt"}, "/files/a/b.txt", nil},
+		{"me", map[string]string{"name": "bob"}, "/@me/bob.json", nil},
+		{"post", map[string]string{"id": "1"}, "", ErrParams},
+		{"post", map[string]string{"id": "x", "pid": "2"}, "", ErrParams},
+		{"post", map[string]string{"id": "1", "pid": "2", "other": "3"}, "", ErrParams},
+		{"post", map[string]string{"id": "1", "pid": "a/b"}, "/users/1/posts/a/b", nil},
+		{"/users/@id/posts", map[string]string{"id": "a/b"}, "", ErrParams},
+		{"archive", map[string]string{"year": "19"}, "", ErrParams},
+		{"post", map[string]string{"id": "", "pid": "2"}, "", ErrParams},
+		{"/@a?/b", nil, "", ErrInvalid},
+		// Mistyped names aren't taken for patterns.
+		{"psot", nil, "", ErrName},
+		{"static", nil, "", ErrName},
+	} {
+		// Patterns are told apart from names by their leading delimiter.
+		build := tr.Build
+		if strings.HasPrefix(tc.pattern, "/") {
+			build = tr.BuildPattern
+		}
+		got, err := build(tc.pattern, tc.params)
+		if tc.err != nil {
+			assert.True(t, errors.Is(err, tc.err), "%s: %v", tc.pattern, err)
+			continue
+		}
+		if assert.Nil(t, err, tc.pattern) {
+			assert.Equal(t, tc.want, got, tc.pattern)
+			n, _ := tr.Get(got)
+			assert.NotNil(t, n, got)
+		}
+	}
+
+	tr = (&Settings{Delimiter: '/', Syntax: BraceSyntax}).New()
+	assert.Nil(t, tr.AddNamed("static", "/static/{path...}", 1))
+	got, err := tr.Build("static", map[string]string{"path": "css/main.css"})
+	assert.Nil(t, err)
+	assert.Equal(t, "/static/css/main.css", got)
+	_, err = tr.BuildPattern("/users/{id}", map[string]string{"id": "a/b"})
+	assert.True(t, errors.Is(err, ErrParams))
+}
+
+func TestAddNamedRace(t *testing.T) {
+	for _, flags := range []int{Tsafe, Tatomic} {
+		tr := (&Settings{Flags: flags, Escape: '@', Delimiter: '/'}).New()
+		var wg sync.WaitGroup
+		errs := make([]error, 8)
+		for i := range errs {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				errs[i] = tr.AddNamed("user", "/users"+strconv.Itoa(i)+"/@id", i)
+			}(i)
+		}
+		wg.Wait()
+		// Only the label registered under the name is added.
+		label, ok := tr.Pattern("user")
+		assert.True(t, ok)
+		assert.Equal(t, 1, tr.Len()-1)
+		for i, err := range errs {
+			if "/users"+strconv.Itoa(i)+"/@id" == label {
+				assert.Nil(t, err)
+				continue
+			}
+			assert.Equal(t, ErrName, err)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package radix_test
+
+import (
+	"testing"
+	"unicode/utf8"
+
+	. "github.com/knnat/radix"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunes(t *testing.T) {
+	labels := []string{"caf", "cafè", "café", "cafés", "naïf", "naïve", "naïveté", "日曜", "日本", "日本語"}
+	s := &Settings{Flags: Trunes | Tnocolor, Escape: '@', Delimiter: '/'}
+	b := s.NewBuilder(len(labels))
+	tr := s.New()
+	for i, l := range labels {
+		assert.Nil(t, b.Add(l, i))
+		assert.Nil(t, tr.Add(labels[len(labels)-1-i], len(labels)-1-i))
+	}
+	plain := New()
+	for i, l := range labels {
+		assert.Nil(t, plain.Add(l, i))
+	}
+	assert.False(t, utf8.ValidString(plain.String()))
+	for _, tr := range []*Tree{tr, b.Tree()} {
+		assert.True(t, utf8.ValidString(tr.String()), tr.String())
+		for i, l := range labels {
+			n, _ := tr.Get(l)
+			if assert.NotNil(t, n, l) {
+				assert.Equal(t, i, n.Value, l)
+			}
+		}
+		n, _ := tr.Get("cafê")
+		assert.Nil(t, n)
+		var walked []string
+		tr.Walk(func(label string, n *Node) bool {
+			walked = append(walked, label)
+			return true
+		})
+		assert.Equal(t, labels, walked)
+
+		// Prefixes ending in the middle of a rune don't match.
+		var prefixed []string
+		tr.WalkPrefix("caf\xc3", func(label string, n *Node) bool {
+			prefixed = append(prefixed, label)
+			return true
+		})
+		assert.Empty(t, prefixed)
+		tr.WalkPrefix("caf", func(label string, n *Node) bool {
+			prefixed = append(prefixed, label)
+			return true
+		})
+		assert.Equal(t, []string{"caf", "cafè", "café", "cafés"}, prefixed)
+		assert.Empty(t, tr.TopK("日\xe6", 10))
+		assert.Len(t, tr.TopK("日", 10), 3)
+		assert.Equal(t, []Entry{{Label: "caf", Node: mustGet(t, tr, "caf")}}, tr.PrefixesOf("cafe"))
+
+		// Distances count runes.
+		var fuzzy []string
+		for _, m := range tr.Fuzzy("cafe", 1) {
+			fuzzy = append(fuzzy, m.Label)
+			assert.True(t, m.Distance <= 1)
+		}
+		assert.Equal(t, []string{"caf", "cafè", "café"}, fuzzy)
+	}
+	assert.Len(t, plain.Fuzzy("cafe", 1), 1)
+
+	tr.Del("café")
+	n, _ := tr.Get("cafés")
+	assert.NotNil(t, n)
+	n, _ = tr.Get("café")
+	assert.Nil(t, n)
+	assert.True(t, utf8.ValidString(tr.String()))
+}
+
+func mustGet(t *testing.T, tr *Tree, label string) *Node {
+	n, _ := tr.Get(label)
+	assert.NotNil(t, n, label)
+	return n
+}
@@ -0,0 +1,438 @@
+package radix
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sync"
+	"unicode/utf8"
+)
+
+// ShardedTree is a radix tree partitioned into independently locked trees,
+// which lets writers of different partitions run concurrently.
+//
+// Labels are partitioned by their first byte into contiguous ranges,
+// so shards hold disjoint sets of the root's edges and, together,
+// have exactly the same structure as a single tree.
+//
+// It has the same methods as Tree. Lookups of a single label only lock
+// the shards that may hold it, while queries that may span several
+// shards, such as Fuzzy or Tokenize, read all of them at once.
+type ShardedTree struct {
+	shards []*Tree
+	escape byte
+	bd     *printer
+	mu     sync.Mutex // held while registering names
+	names  sync.Map   // labels by name, for Build
+}
+
+// NewSharded creates a radix tree split into n shards.
+// Shards are always thread safe.
+func (s *Settings) NewSharded(n int) *ShardedTree {
+	if n < 1 {
+		n = 1
+	}
+	if n > 256 {
+		n = 256
+	}
+	ss := *s
+	ss.Flags |= Tsafe
+	st := &ShardedTree{
+		shards: make([]*Tree, n),
+		escape: s.escape(),
+	}
+	for i := range st.shards {
+		st.shards[i] = ss.New()
+	}
+	st.bd = st.shards[0].bd
+	return st
+}
+
+// NewSharded creates a radix tree split into n shards with the default settings.
+func NewSharded(n int) *ShardedTree {
+	return defaults.NewSharded(n)
+}
+
+// shard returns the shard that holds labels starting with c.
+func (st *ShardedTree) shard(c byte) *Tree {
+	return st.shards[int(c)*len(st.shards)/256]
+}
+
+// first returns the first byte of a label once normalized
+// by the key normalizer of the tree, if any.
+func (st *ShardedTree) first(label string) byte {
+	tr := st.shards[0]
+	if tr.norm == nil || label[0] == tr.escape {
+		return label[0]
+	}
+	var buf [utf8.UTFMax]byte
+	b, _ := tr.appendNormal(buf[:0], label, 0)
+	return b[0]
+}
+
+// Add adds a new node to the tree.
+func (st *ShardedTree) Add(label string, v interface{}) error {
+	if label == "" {
+		return nil
+	}
+	return st.shard(st.first(label)).Add(label, v)
+}
+
+// Set adds a new node to the tree or, if the label
+// already exists, replaces its node's value.
+func (st *ShardedTree) Set(label string, v interface{}) error {
+	if label == "" {
+		return nil
+	}
+	return st.shard(st.first(label)).Set(label, v)
+}
+
+// Del deletes a node.
+func (st *ShardedTree) Del(label string) {
+	if label == "" {
+		return
+	}
+	st.shard(st.first(label)).Del(label)
+}
+
+// Get retrieves a node.
+func (st *ShardedTree) Get(label string) (*Node, map[string]string) {
+	if label == "" {
+		return nil, nil
+	}
+	// Static edges are tried first, then the ones holding a placeholder,
+	// which may be stored in another shard.
+	if n, p, ok := st.getShard(label, st.first(label)); ok {
+		return n, p
+	}
+	n, p, _ := st.getShard(label, st.escape)
+	return n, p
+}
+
+// getShard retrieves a node through the root edges starting with c.
+// It reports whether one was found.
+func (st *ShardedTree) getShard(label string, c byte) (*Node, map[string]string, bool) {
+	tr := st.shard(c)
+	defer tr.runlock()
+	return tr.getRoot(tr.rlock().root, label, c)
+}
+
+// Len returns the total numbers of nodes,
+// including the tree's root.
+func (st *ShardedTree) Len() int {
+	length := 1
+	for _, tr := range st.shards {
+		length += tr.Len() - 1 // each shard has its own root
+	}
+	return length
+}
+
+// Size returns the total byte size stored in the tree.
+func (st *ShardedTree) Size() int {
+	var size int
+	for _, tr := range st.shards {
+		size += tr.Size()
+	}
+	return size
+}
+
+// Sort sorts the tree nodes and its children recursively
+// according to their priority lengther.
+func (st *ShardedTree) Sort(s SortingTechnique) {
+	for _, tr := range st.shards {
+		tr.Sort(s)
+	}
+}
+
+// Batch runs fn with a batch for the tree, locking all shards.
+//
+// If fn returns an error or panics, all operations made
+// through the batch are reverted, leaving the tree unchanged,
+// and the error is returned.
+func (st *ShardedTree) Batch(fn func(b *Batch) error) error {
+	for _, tr := range st.shards {
+		defer tr.mu.Unlock()
+		tr.mu.Lock()
+		if tr.atomic {
+			defer tr.publish()
+		}
+	}
+	b := &Batch{
+		trees: st.shards,
+		tree:  func(label string) *Tree { return st.shard(st.first(label)) },
+		get: func(label string) (*Node, map[string]string) {
+			c := st.first(label)
+			tr := st.shard(c)
+			if n, p, ok := tr.getRoot(tr.root, label, c); ok {
+				return n, p
+			}
+			tr = st.shard(st.escape)
+			n, p, _ := tr.getRoot(tr.root, label, st.escape)
+			return n, p
+		},
+	}
+	return b.run(fn)
+}
+
+// Walk calls fn for every node holding a value, in ascending
+// order of their labels, until fn returns false.
+//
+// Shards are read one after another, so changes made to
+// other shards during the walk may or may not be seen.
+// The tree must not be modified by fn.
+func (st *ShardedTree) Walk(fn func(label string, n *Node) bool) {
+	for _, tr := range st.shards {
+		ok := true
+		tr.Walk(func(label string, n *Node) bool {
+			ok = fn(label, n)
+			return ok
+		})
+		if !ok {
+			return
+		}
+	}
+}
+
+// WalkPrefix calls fn for every node holding a value whose label starts
+// with prefix, in ascending order of their labels, until fn returns false.
+//
+// Placeholders are not expanded, labels are compared as they were added.
+// The tree must not be modified by fn.
+func (st *ShardedTree) WalkPrefix(prefix string, fn func(label string, n *Node) bool) {
+	if prefix == "" {
+		st.Walk(fn)
+		return
+	}
+	st.shard(st.first(prefix)).WalkPrefix(prefix, fn)
+}
+
+// String returns a string representation of the tree structure.
+func (st *ShardedTree) String() string {
+	defer st.runlock()
+	return st.rlock().String()
+}
+
+// rlock locks all shards for reading and returns a tree, which must only
+// be read, whose root holds the root edges of all of them, so that queries
+// spanning several shards are run as they are on a single tree.
+func (st *ShardedTree) rlock() *Tree {
+	proto := st.shards[0]
+	tr := &Tree{
+		root:   &Node{best: math.Inf(-1)},
+		length: 1,
+		escape: proto.escape,
+		delim:  proto.delim,
+		syntax: proto.syntax,
+		norm:   proto.norm,
+		runes:  proto.runes,
+		bd:     proto.bd,
+	}
+	for _, s := range st.shards {
+		v := s.rlock()
+		for _, e := range v.root.edges {
+			tr.root.addEdge(e, tr.escape)
+		}
+		tr.length += v.length - 1
+		tr.size += v.size
+		tr.root.best = math.Max(tr.root.best, v.root.best)
+	}
+	return tr
+}
+
+// runlock undoes a single call to rlock.
+func (st *ShardedTree) runlock() {
+	for _, tr := range st.shards {
+		tr.runlock()
+	}
+}
+
+// AddBytes is like Add, but for a label held by a byte slice.
+// The tree keeps a copy of the label, not the slice.
+func (st *ShardedTree) AddBytes(label []byte, v interface{}) error {
+	return st.Add(string(label), v)
+}
+
+// GetBytes is like Get, but for a label held by a byte slice.
+//
+// It doesn't allocate, except for the params, whose values are copied
+// so that they don't change along with the slice.
+func (st *ShardedTree) GetBytes(label []byte) (*Node, map[string]string) {
+	n, params := st.Get(unsafeString(label))
+	for k, v := range params {
+		params[k] = string([]byte(v))
+	}
+	return n, params
+}
+
+// DelBytes is like Del, but for a label held by a byte slice.
+func (st *ShardedTree) DelBytes(label []byte) {
+	st.Del(unsafeString(label))
+}
+
+// LongestPrefixBytes is like LongestPrefix, but for a byte slice,
+// returning the part of the slice that the label found matches.
+// It doesn't allocate, unless the tree has a key normalizer.
+func (st *ShardedTree) LongestPrefixBytes(b []byte) ([]byte, *Node, bool) {
+	label, n, ok := st.LongestPrefix(unsafeString(b))
+	return b[:len(label)], n, ok
+}
+
+// PrefixesOf returns all labels that are prefixes of s,
+// including s itself, from the shortest to the longest.
+//
+// Placeholders are not expanded, labels are compared as they were added.
+func (st *ShardedTree) PrefixesOf(s string) []Entry {
+	if s == "" {
+		return nil
+	}
+	return st.shard(st.first(s)).PrefixesOf(s)
+}
+
+// WalkPrefixesOf calls fn for every node holding a value whose label is a
+// prefix of s, from the shortest to the longest, until fn returns false.
+//
+// The tree must not be modified by fn.
+func (st *ShardedTree) WalkPrefixesOf(s string, fn func(label string, n *Node) bool) {
+	if s == "" {
+		return
+	}
+	st.shard(st.first(s)).WalkPrefixesOf(s, fn)
+}
+
+// LongestPrefix returns the longest label that is a prefix of s,
+// along with its node, and reports whether there is one.
+func (st *ShardedTree) LongestPrefix(s string) (string, *Node, bool) {
+	if s == "" {
+		return "", nil, false
+	}
+	return st.shard(st.first(s)).LongestPrefix(s)
+}
+
+// SetWeight sets the weight of the node holding the label,
+// used for ranking by TopK. Nodes weigh 0 by default.
+//
+// It reports whether there is a node holding the label.
+func (st *ShardedTree) SetWeight(label string, weight float64) bool {
+	if label == "" {
+		return false
+	}
+	return st.shard(st.first(label)).SetWeight(label, weight)
+}
+
+// TopK returns at most k labels starting with prefix that have the
+// highest weights, in descending order of weight and then by label.
+func (st *ShardedTree) TopK(prefix string, k int) []Completion {
+	if prefix != "" {
+		return st.shard(st.first(prefix)).TopK(prefix, k)
+	}
+	defer st.runlock()
+	return st.rlock().TopK(prefix, k)
+}
+
+// Explain looks a label up as Get does and returns a trace of the
+// nodes visited, the edges tried, the reasons they were rejected and
+// the values captured by placeholders along the way.
+func (st *ShardedTree) Explain(label string) *Trace {
+	defer st.runlock()
+	return st.rlock().Explain(label)
+}
+
+// Fuzzy returns all labels within maxDist edits of query,
+// as (*Tree).Fuzzy does.
+func (st *ShardedTree) Fuzzy(query string, maxDist int) []FuzzyMatch {
+	defer st.runlock()
+	return st.rlock().Fuzzy(query, maxDist)
+}
+
+// FuzzyDamerau is like Fuzzy, but also counts a transposition
+// of two adjacent runes as a single edit.
+func (st *ShardedTree) FuzzyDamerau(query string, maxDist int) []FuzzyMatch {
+	defer st.runlock()
+	return st.rlock().FuzzyDamerau(query, maxDist)
+}
+
+// MatchGlob returns all labels matching a glob pattern, in ascending
+// order, as (*Tree).MatchGlob does.
+func (st *ShardedTree) MatchGlob(pattern string) ([]Entry, error) {
+	defer st.runlock()
+	return st.rlock().MatchGlob(pattern)
+}
+
+// MatchRegexp returns all labels matching re, in ascending order.
+func (st *ShardedTree) MatchRegexp(re *regexp.Regexp) []Entry {
+	defer st.runlock()
+	return st.rlock().MatchRegexp(re)
+}
+
+// List lists labels starting with prefix, as (*Tree).List does.
+func (st *ShardedTree) List(prefix string, opts ListOptions) ListResult {
+	defer st.runlock()
+	return st.rlock().List(prefix, opts)
+}
+
+// Matcher builds an Aho-Corasick automaton from the labels of all shards.
+func (st *ShardedTree) Matcher() *Matcher {
+	defer st.runlock()
+	return st.rlock().Matcher()
+}
+
+// Tokenize splits s into labels of the tree using maximal munch,
+// as (*Tree).Tokenize does.
+func (st *ShardedTree) Tokenize(s string) (tokens, unknown []Token) {
+	defer st.runlock()
+	return st.rlock().Tokenize(s)
+}
+
+// TokenizeMin is like Tokenize, but splits s into the fewest
+// tokens possible, after leaving the fewest runes unknown.
+func (st *ShardedTree) TokenizeMin(s string) (tokens, unknown []Token) {
+	defer st.runlock()
+	return st.rlock().TokenizeMin(s)
+}
+
+// AddNamed adds a new node to the tree, like Add, and registers
+// its label under a name, so that Build can be given the name.
+//
+// Unlike Add, it returns ErrInvalid for an empty label or a nil value.
+// Names stay registered when their labels are deleted.
+func (st *ShardedTree) AddNamed(name, label string, v interface{}) error {
+	if label == "" || v == nil {
+		return ErrInvalid
+	}
+	defer st.mu.Unlock()
+	st.mu.Lock()
+	if l, ok := st.names.Load(name); ok && l.(string) != label {
+		return ErrName
+	}
+	if err := st.Add(label, v); err != nil {
+		return err
+	}
+	st.names.Store(name, label)
+	return nil
+}
+
+// Pattern returns the label registered under a name.
+func (st *ShardedTree) Pattern(name string) (string, bool) {
+	l, ok := st.names.Load(name)
+	if !ok {
+		return "", false
+	}
+	return l.(string), true
+}
+
+// Build returns the label that the label registered under the given
+// name leads to once its placeholders are filled with params.
+// Names that aren't registered are errors wrapping ErrName.
+func (st *ShardedTree) Build(name string, params map[string]string) (string, error) {
+	l, ok := st.names.Load(name)
+	if !ok {
+		return "", fmt.Errorf("%w: %q isn't registered", ErrName, name)
+	}
+	return st.BuildPattern(l.(string), params)
+}
+
+// BuildPattern returns the label a pattern leads to
+// once its placeholders are filled with params.
+func (st *ShardedTree) BuildPattern(pattern string, params map[string]string) (string, error) {
+	return st.shards[0].BuildPattern(pattern, params)
+}
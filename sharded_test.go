@@ -0,0 +1,175 @@
+package radix_test
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sync"
+	"testing"
+
+	. "github.com/knnat/radix"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShardedTree(t *testing.T) {
+	settings := &Settings{Flags: Tnocolor | Tdebug, Escape: '@', Delimiter: '/'}
+	labels := []string{
+		"/users", "/users/@id", "@name", "AB", "romane", "romanus", "romulus",
+		"rubens", "ruber", "rubicon", "rubicundus", "zz",
+	}
+	want := settings.New()
+	got := settings.NewSharded(4)
+	for i, l := range labels {
+		assert.Nil(t, want.Add(l, i))
+		assert.Nil(t, got.Add(l, i))
+	}
+	assert.EqualError(t, got.Add("romane", 0), ErrEscape.Error())
+	assert.Equal(t, want.String(), got.String())
+	assert.Equal(t, want.Len(), got.Len())
+	assert.Equal(t, want.Size(), got.Size())
+
+	for _, l := range []string{"/users/123", "foo", "romane", "rubicon", "zz", "rom"} {
+		wn, wp := want.Get(l)
+		gn, gp := got.Get(l)
+		if wn == nil {
+			assert.Nil(t, gn, l)
+		} else if assert.NotNil(t, gn, l) {
+			assert.Equal(t, wn.Value, gn.Value, l)
+		}
+		assert.Equal(t, wp, gp, l)
+	}
+	n, p := got.Get("foo")
+	assert.Equal(t, 2, n.Value)
+	assert.Equal(t, "foo", p["name"])
+
+	var walked []string
+	got.Walk(func(label string, n *Node) bool {
+		walked = append(walked, label)
+		return true
+	})
+	var sorted []string
+	want.Walk(func(label string, n *Node) bool {
+		sorted = append(sorted, label)
+		return true
+	})
+	assert.Equal(t, sorted, walked)
+	assert.Equal(t, []string{"/users", "/users/@id", "@name", "AB", "romane", "romanus", "romulus",
+		"rubens", "ruber", "rubicon", "rubicundus", "zz"}, walked)
+
+	walked = nil
+	got.WalkPrefix("rub", func(label string, n *Node) bool {
+		walked = append(walked, label)
+		return len(walked) < 3
+	})
+	assert.Equal(t, []string{"rubens", "ruber", "rubicon"}, walked)
+
+	err := got.Batch(func(b *Batch) error {
+		assert.Nil(t, b.Set("zz", 100))
+		assert.Nil(t, b.Add("aa", 101))
+		b.Del("/users")
+		return fmt.Errorf("rollback")
+	})
+	assert.NotNil(t, err)
+	assert.Equal(t, want.String(), got.String())
+
+	for _, l := range labels {
+		got.Del(l)
+	}
+	assert.Equal(t, 1, got.Len())
+	assert.Equal(t, 0, got.Size())
+}
+
+func TestShardedTreeQueries(t *testing.T) {
+	settings := &Settings{Flags: Tnocolor | Tdebug, Escape: '@', Delimiter: '/'}
+	labels := []string{
+		"/users", "/users/@id", "@name", "AB", "romane", "romanus", "romulus",
+		"rubens", "ruber", "rubicon", "rubicundus", "zz", "z",
+	}
+	want := settings.New()
+	got := settings.NewSharded(4)
+	for i, l := range labels {
+		assert.Nil(t, want.AddBytes([]byte(l), i))
+		assert.Nil(t, got.AddBytes([]byte(l), i))
+		assert.True(t, want.SetWeight(l, float64(i%5)))
+		assert.True(t, got.SetWeight(l, float64(i%5)))
+	}
+	assert.Nil(t, want.AddNamed("user", "/users/@id/posts", 20))
+	assert.Nil(t, got.AddNamed("user", "/users/@id/posts", 20))
+	assert.Equal(t, ErrName, got.AddNamed("user", "/posts", 21))
+	assert.Equal(t, want.String(), got.String())
+
+	params := map[string]string{"id": "1"}
+	wl, werr := want.Build("user", params)
+	gl, gerr := got.Build("user", params)
+	assert.Equal(t, wl, gl)
+	assert.Equal(t, werr, gerr)
+	_, gerr = got.Build("usr", params)
+	assert.True(t, errors.Is(gerr, ErrName))
+	gl, _ = got.BuildPattern("/users/@id", params)
+	assert.Equal(t, "/users/1", gl)
+
+	n, p := got.GetBytes([]byte("/users/2/posts"))
+	if assert.NotNil(t, n) {
+		assert.Equal(t, 20, n.Value)
+		assert.Equal(t, "2", p["id"])
+	}
+	assert.Equal(t, want.Explain("/users/2/posts").String(), got.Explain("/users/2/posts").String())
+	assert.Equal(t, want.Explain("foo").String(), got.Explain("foo").String())
+
+	assert.Equal(t, want.PrefixesOf("rubiconx"), got.PrefixesOf("rubiconx"))
+	prefix, n, ok := got.LongestPrefixBytes([]byte("zzz"))
+	assert.True(t, ok)
+	assert.Equal(t, "zz", string(prefix))
+	assert.Equal(t, 11, n.Value)
+	assert.Equal(t, want.TopK("", 4), got.TopK("", 4))
+	assert.Equal(t, want.TopK("ru", 2), got.TopK("ru", 2))
+	assert.Equal(t, want.Fuzzy("rubes", 1), got.Fuzzy("rubes", 1))
+	assert.Equal(t, want.FuzzyDamerau("zA", 2), got.FuzzyDamerau("zA", 2))
+	we, werr := want.MatchGlob("r*s")
+	ge, gerr := got.MatchGlob("r*s")
+	assert.Equal(t, we, ge)
+	assert.Equal(t, werr, gerr)
+	re := regexp.MustCompile("^(AB|z+)$")
+	assert.Equal(t, want.MatchRegexp(re), got.MatchRegexp(re))
+	opts := ListOptions{MaxKeys: 3, Marker: "AB"}
+	assert.Equal(t, want.List("", opts), got.List("", opts))
+	wt, wu := want.Tokenize("zzABromane?")
+	gt, gu := got.Tokenize("zzABromane?")
+	assert.Equal(t, wt, gt)
+	assert.Equal(t, wu, gu)
+	wt, wu = want.TokenizeMin("zzABromane?")
+	gt, gu = got.TokenizeMin("zzABromane?")
+	assert.Equal(t, wt, gt)
+	assert.Equal(t, wu, gu)
+	assert.Equal(t, want.Matcher().FindAll("AB romulus zz"), got.Matcher().FindAll("AB romulus zz"))
+
+	got.DelBytes([]byte("zz"))
+	label, _, ok := got.LongestPrefix("zzz")
+	assert.True(t, ok)
+	assert.Equal(t, "z", label)
+}
+
+func TestShardedTreeRace(t *testing.T) {
+	st := NewSharded(8)
+	var wg sync.WaitGroup
+	for i := 0; i < 32; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				label := fmt.Sprintf("%c%d/%d", 'a'+i%26, i, j)
+				st.Add(label, j)
+				st.Get(label)
+				// Queries spanning all shards read them at once.
+				st.Tokenize(label)
+			}
+		}(i)
+	}
+	wg.Wait()
+	var count int
+	st.Walk(func(string, *Node) bool {
+		count++
+		return true
+	})
+	assert.Equal(t, 3200, count)
+}
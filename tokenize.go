@@ -0,0 +1,125 @@
+package radix
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// Token is a span of a tokenized string. Tokens
+// that are not labels of the tree have a nil node.
+type Token struct {
+	Label  string
+	Node   *Node
+	Offset int // byte offset of the token in the string
+}
+
+// Tokenize splits s into labels of the tree using maximal munch,
+// taking the longest label at each position.
+//
+// Spans of s that don't start with any label are returned in unknown,
+// split at rune boundaries. Tokens includes them too, in order, so that
+// concatenating all tokens' labels gives back s.
+//
+// Placeholders are not expanded, labels are compared as they were added.
+// If the tree has a key normalizer, s is looked up as it maps it, but
+// tokens' labels and offsets are those of s as it was given.
+func (tr *Tree) Tokenize(s string) (tokens, unknown []Token) {
+	defer tr.runlock()
+	root := tr.rlock().root
+	sr := tr.newSearch(s)
+	key := sr.key
+	var t tokenizer
+	for i := 0; i < len(key); {
+		var n *Node
+		size := 0
+		tnode := root
+		for j := i; j < len(key); {
+			e := tr.childFor(tnode, key[j:])
+			if e == nil || !strings.HasPrefix(key[j:], e.label) {
+				break
+			}
+			j += len(e.label)
+			tnode = e.node
+			if tnode.Value != nil {
+				n, size = tnode, j-i
+			}
+		}
+		if n == nil {
+			_, size = utf8.DecodeRuneInString(key[i:])
+		}
+		t.add(s, sr.offset(i), sr.offset(i+size), n)
+		i += size
+	}
+	return t.tokens, t.unknown
+}
+
+// TokenizeMin is like Tokenize, but splits s into the fewest
+// tokens possible, after leaving the fewest runes unknown.
+func (tr *Tree) TokenizeMin(s string) (tokens, unknown []Token) {
+	// All positions are looked up in the same version of the tree.
+	defer tr.runlock()
+	root := tr.rlock().root
+	sr := tr.newSearch(s)
+	key := sr.key
+	type step struct {
+		unknown, tokens int   // cost of reaching the position
+		prev            int   // position the last token starts at, or -1
+		node            *Node // node of the last token
+	}
+	steps := make([]step, len(key)+1)
+	for i := 1; i < len(steps); i++ {
+		steps[i].prev = -1
+	}
+	better := func(j int, unknown, tokens int) bool {
+		st := steps[j]
+		return st.prev < 0 || unknown < st.unknown || unknown == st.unknown && tokens < st.tokens
+	}
+	for i := 0; i < len(key); i++ {
+		if i > 0 && steps[i].prev < 0 {
+			continue // inside a rune
+		}
+		cur := steps[i]
+		tr.walkPrefixesOf(root, key, i, func(j int, n *Node) bool {
+			if better(j, cur.unknown, cur.tokens+1) {
+				steps[j] = step{unknown: cur.unknown, tokens: cur.tokens + 1, prev: i, node: n}
+			}
+			return true
+		})
+		_, size := utf8.DecodeRuneInString(key[i:])
+		if j := i + size; better(j, cur.unknown+1, cur.tokens) {
+			steps[j] = step{unknown: cur.unknown + 1, tokens: cur.tokens, prev: i}
+		}
+	}
+	var ends []int
+	for j := len(key); j > 0; j = steps[j].prev {
+		ends = append(ends, j)
+	}
+	var t tokenizer
+	for k := len(ends) - 1; k >= 0; k-- {
+		j := ends[k]
+		t.add(s, sr.offset(steps[j].prev), sr.offset(j), steps[j].node)
+	}
+	return t.tokens, t.unknown
+}
+
+// tokenizer collects tokens, merging adjacent unknown spans.
+type tokenizer struct {
+	tokens  []Token
+	unknown []Token
+}
+
+func (t *tokenizer) add(s string, start, end int, n *Node) {
+	if n == nil && len(t.unknown) > 0 {
+		last := &t.unknown[len(t.unknown)-1]
+		if last.Offset+len(last.Label) == start {
+			last.Label = s[last.Offset:end]
+			t.tokens[len(t.tokens)-1] = *last
+			return
+		}
+	}
+	tok := Token{Label: s[start:end], Node: n, Offset: start}
+	t.tokens = append(t.tokens, tok)
+	if n == nil {
+		t.unknown = append(t.unknown, tok)
+	}
+}
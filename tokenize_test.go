@@ -0,0 +1,64 @@
+package radix_test
+
+import (
+	"strings"
+	"testing"
+
+	. "github.com/knnat/radix"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTokenize(t *testing.T) {
+	tr := New()
+	for _, l := range []string{"the", "them", "theme", "me", "men", "end", "a", "at", "tea", "mend", "s"} {
+		assert.Nil(t, tr.Add(l, l))
+	}
+	labels := func(tokens []Token) []string {
+		var labels []string
+		for _, tok := range tokens {
+			if tok.Node != nil {
+				assert.Equal(t, tok.Label, tok.Node.Value)
+			}
+			labels = append(labels, tok.Label)
+		}
+		return labels
+	}
+	tokens, unknown := tr.Tokenize("themend")
+	assert.Equal(t, []string{"theme", "nd"}, labels(tokens))
+	assert.Equal(t, []string{"nd"}, labels(unknown))
+	assert.Equal(t, 5, unknown[0].Offset)
+	assert.Nil(t, unknown[0].Node)
+
+	tokens, unknown = tr.TokenizeMin("themend")
+	assert.Equal(t, []string{"the", "mend"}, labels(tokens))
+	assert.Nil(t, unknown)
+
+	tokens, unknown = tr.Tokenize("xxtheméat")
+	assert.Equal(t, []string{"xx", "them", "é", "at"}, labels(tokens))
+	assert.Equal(t, []string{"xx", "é"}, labels(unknown))
+	assert.Equal(t, []int{0, 6}, []int{unknown[0].Offset, unknown[1].Offset})
+
+	tokens, unknown = tr.TokenizeMin("xxtheméats")
+	assert.Equal(t, []string{"xx", "them", "é", "at", "s"}, labels(tokens))
+	assert.Equal(t, []string{"xx", "é"}, labels(unknown))
+
+	tokens, unknown = tr.Tokenize("")
+	assert.Nil(t, tokens)
+	assert.Nil(t, unknown)
+	tokens, _ = tr.TokenizeMin("")
+	assert.Nil(t, tokens)
+
+	// Tokens always add up to the whole string.
+	for _, s := range []string{"attheteamends", "meanthemes", "aé日本s"} {
+		for _, tokenize := range []func(string) ([]Token, []Token){tr.Tokenize, tr.TokenizeMin} {
+			tokens, _ := tokenize(s)
+			assert.Equal(t, s, strings.Join(labels(tokens), ""))
+		}
+	}
+	tokens, _ = tr.Tokenize("attheteamends")
+	assert.Equal(t, []string{"at", "the", "tea", "mend", "s"}, labels(tokens))
+	tokens, _ = tr.Tokenize("themenda")
+	assert.Equal(t, []string{"theme", "nd", "a"}, labels(tokens))
+	tokens, _ = tr.TokenizeMin("themenda")
+	assert.Equal(t, []string{"the", "mend", "a"}, labels(tokens))
+}
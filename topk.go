@@ -0,0 +1,183 @@
+package radix
+
+import (
+	"container/heap"
+	"math"
+	"strings"
+	"unicode/utf8"
+)
+
+// Completion is a label found by a top-k lookup.
+type Completion struct {
+	Label  string
+	Node   *Node
+	Weight float64
+}
+
+// SetWeight sets the weight of the node holding the label,
+// used for ranking by TopK. Nodes weigh 0 by default.
+//
+// It reports whether there is a node holding the label.
+func (tr *Tree) SetWeight(label string, weight float64) bool {
+	if label == "" {
+		return false
+	}
+	if tr.safe {
+		defer tr.mu.Unlock()
+		tr.mu.Lock()
+	}
+	label = tr.normalize(label)
+	if tr.atomic {
+		defer tr.publish()
+	}
+	if tr.copying() {
+		tr.own(label)
+	}
+	tnode := tr.root
+	for s := label; s != ""; {
+		e := tr.childFor(tnode, s)
+		if e == nil || !strings.HasPrefix(s, e.label) {
+			return false
+		}
+		tnode = e.node
+		s = s[len(e.label):]
+	}
+	if tnode.Value == nil {
+		return false
+	}
+	tnode.weight = weight
+	tr.weighted = true
+	tr.reweigh(label)
+	return true
+}
+
+// reweigh updates the highest weights of the subtrees in the label's path.
+func (tr *Tree) reweigh(label string) {
+	path := []*Node{tr.root}
+	tnode := tr.root
+	for label != "" {
+		e := tr.childFor(tnode, label)
+		if e == nil || !strings.HasPrefix(label, e.label) {
+			break
+		}
+		tnode = e.node
+		label = label[len(e.label):]
+		path = append(path, tnode)
+	}
+	for i := len(path) - 1; i >= 0; i-- {
+		n := path[i]
+		n.best = math.Inf(-1)
+		if n.Value != nil {
+			n.best = n.weight
+		}
+		for _, e := range n.edges {
+			if e.node.best > n.best {
+				n.best = e.node.best
+			}
+		}
+	}
+}
+
+// TopK returns at most k labels starting with prefix that have the
+// highest weights, in descending order of weight and then by label.
+//
+// Subtrees are visited best first, by the highest weight they hold,
+// so only the parts of the tree that lead to the results are visited.
+// Placeholders are not expanded, labels are compared as they were added.
+func (tr *Tree) TopK(prefix string, k int) []Completion {
+	if k <= 0 {
+		return nil
+	}
+	prefix = tr.normalize(prefix)
+	defer tr.runlock()
+	tnode := tr.rlock().root
+	label := prefix
+	size := len(prefix)
+	depth := 0
+	for len(prefix) > 0 {
+		e := tr.childFor(tnode, prefix)
+		if e == nil {
+			return nil
+		}
+		if !strings.HasPrefix(prefix, e.label) {
+			if !strings.HasPrefix(e.label, prefix) {
+				return nil
+			}
+			// The prefix ends in the middle of the edge.
+			label += e.label[len(prefix):]
+			prefix = ""
+		} else {
+			prefix = prefix[len(e.label):]
+		}
+		tnode = e.node
+		depth++
+	}
+	if tr.runes && len(label) > size && !utf8.RuneStart(label[size]) {
+		// The prefix ends in the middle of a rune.
+		return nil
+	}
+	var results []Completion
+	q := &candidates{{label: label, node: tnode, depth: depth, weight: tnode.best}}
+	for q.Len() > 0 && len(results) < k {
+		c := heap.Pop(q).(candidate)
+		if c.leaf {
+			results = append(results, Completion{
+				Label:  c.label,
+				Node:   c.node.at(c.depth),
+				Weight: c.weight,
+			})
+			continue
+		}
+		if c.node.Value != nil {
+			heap.Push(q, candidate{label: c.label, node: c.node, depth: c.depth, weight: c.node.weight, leaf: true})
+		}
+		for _, e := range c.node.edges {
+			heap.Push(q, candidate{label: c.label + e.label, node: e.node, depth: c.depth + 1, weight: e.node.best})
+		}
+	}
+	return results
+}
+
+// candidate is either a node's own label, if leaf is set,
+// or the whole subtree below it, weighing its highest weight.
+type candidate struct {
+	label  string
+	node   *Node
+	depth  int
+	weight float64
+	leaf   bool
+}
+
+// candidates is a max heap of candidates. Ties are broken by label and
+// then by placing leaves first, so that results are ordered by label, as
+// all labels in a subtree come after the label that leads to it.
+type candidates []candidate
+
+func (c candidates) Len() int {
+	return len(c)
+}
+
+func (c candidates) Less(i, j int) bool {
+	if c[i].weight != c[j].weight {
+		return c[i].weight > c[j].weight
+	}
+	if c[i].label != c[j].label {
+		return c[i].label < c[j].label
+	}
+	return c[i].leaf && !c[j].leaf
+}
+
+func (c candidates) Swap(i, j int) {
+	c[i], c[j] = c[j], c[i]
+}
+
+func (c *candidates) Push(x interface{}) {
+	*c = append(*c, x.(candidate))
+}
+
+func (c *candidates) Pop() interface{} {
+	old := *c
+	x := old[len(old)-1]
+	*c = old[:len(old)-1]
+	return x
+}
@@ -0,0 +1,62 @@
+package radix_test
+
+import (
+	"sort"
+	"testing"
+
+	. "github.com/knnat/radix"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTopK(t *testing.T) {
+	for _, flags := range []int{0, Tatomic} {
+		tr := (&Settings{Flags: flags, Escape: '@', Delimiter: '/'}).New()
+		weights := map[string]float64{
+			"romane": 3, "romanus": 10, "romulus": 1, "rubens": 7,
+			"ruber": 7, "rubicon": 2, "rubicundus": 9, "rub": 4,
+		}
+		for l := range weights {
+			assert.Nil(t, tr.Add(l, l))
+		}
+		labels := func(cs []Completion) []string {
+			var labels []string
+			for _, c := range cs {
+				labels = append(labels, c.Label)
+			}
+			return labels
+		}
+		// Without weights, labels are ordered alphabetically.
+		assert.Equal(t, []string{"rub", "rubens", "ruber"}, labels(tr.TopK("ru", 3)))
+
+		for l, w := range weights {
+			assert.True(t, tr.SetWeight(l, w))
+		}
+		assert.False(t, tr.SetWeight("ro", 100))
+		assert.False(t, tr.SetWeight("rubi", 100))
+
+		top := tr.TopK("r", 4)
+		assert.Equal(t, []string{"romanus", "rubicundus", "rubens", "ruber"}, labels(top))
+		assert.Equal(t, 10.0, top[0].Weight)
+		assert.Equal(t, "romanus", top[0].Node.Value)
+
+		assert.Equal(t, []string{"rubicundus", "rubens", "ruber", "rub", "rubicon"}, labels(tr.TopK("rub", 10)))
+		assert.Equal(t, []string{"rubicundus", "rubicon"}, labels(tr.TopK("rubi", 10)))
+		assert.Equal(t, []string{"rubicundus"}, labels(tr.TopK("rubic", 1)))
+		assert.Nil(t, tr.TopK("x", 1))
+		assert.Nil(t, tr.TopK("rubx", 1))
+
+		// Weights survive splits and merges.
+		assert.Nil(t, tr.Add("rubicun", "rubicun"))
+		assert.Nil(t, tr.Add("rubico", "rubico"))
+		assert.True(t, tr.SetWeight("rubico", 20))
+		assert.Equal(t, []string{"rubico", "romanus"}, labels(tr.TopK("", 2)))
+		tr.Del("rubico")
+		tr.Del("romanus")
+		tr.Del("rubicundus")
+		assert.Equal(t, []string{"rubens", "ruber", "rub"}, labels(tr.TopK("", 3)))
+
+		all := tr.TopK("", 100)
+		assert.True(t, sort.SliceIsSorted(all, func(i, j int) bool { return all[i].Weight > all[j].Weight }))
+		assert.Equal(t, 7, len(all))
+	}
+}
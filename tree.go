@@ -2,8 +2,11 @@ package radix
 
 import (
 	"errors"
+	"fmt"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"unicode/utf8"
 
 	"github.com/gbrlsnchs/color"
 )
@@ -15,6 +18,15 @@ const (
 	Tdebug
 	// Tnocolor disables colorful output.
 	Tnocolor
+	// Tatomic activates thread safety with lock-free reads.
+	//
+	// Writers copy the nodes they modify instead of changing them in place
+	// and then atomically publish the new version of the tree, while
+	// readers keep reading the version that was current when they started.
+	Tatomic
+	// Trunes splits edges on rune boundaries only, so that edge labels
+	// stay valid UTF-8, and makes prefix and fuzzy queries count runes.
+	Trunes
 )
 
 // Tree is a radix tree.
@@ -23,10 +35,27 @@ type Tree struct {
 	length int // total number of nodes
 	size   int // total byte size
 	safe   bool
-	escape byte // default '@'
-	delim  byte // default '/'
-	mu     *sync.RWMutex
-	bd     *builder
+	atomic bool
+	runes  bool // whether edges are split on rune boundaries only
+	// Whether any weight has been set, only then
+	// the highest weights of subtrees are kept up to date.
+	weighted bool
+	escape   byte // default '@'
+	delim    byte // default '/'
+	syntax   Syntax
+	norm     KeyNormalizer
+	mu       *sync.RWMutex
+	cur      atomic.Pointer[version] // latest published version, if atomic
+	bd       *printer
+	names    sync.Map  // labels by name, for Build
+	snap     *snapshot // the tree as it was before the running batch
+}
+
+// version is a published state of a tree.
+type version struct {
+	root   *Node
+	length int
+	size   int
 }
 
 // Settings ...
@@ -34,6 +63,13 @@ type Settings struct {
 	Flags     int
 	Escape    byte
 	Delimiter byte
+	// Syntax is the syntax of placeholders. Escape is only
+	// used by EscapeSyntax, the default.
+	Syntax Syntax
+	// KeyNormalizer, if set, maps the runes of labels that are added,
+	// deleted or looked up, such as FoldASCII for case-insensitive ones,
+	// and those of the prefixes, patterns and texts they are searched by.
+	KeyNormalizer KeyNormalizer
 }
 
 var defaults = &Settings{
@@ -50,19 +86,37 @@ var (
 	ErrEscape = errors.New("escape symbols conflict")
 )
 
+// escape returns the byte that starts placeholders in the settings' syntax.
+func (s *Settings) escape() byte {
+	switch s.Syntax {
+	case ColonSyntax:
+		return ':'
+	case BraceSyntax:
+		return '{'
+	}
+	return s.Escape
+}
+
 // New creates a named radix tree with a single node (its root).
 func (s *Settings) New() *Tree {
 	tr := &Tree{
 		root:   &Node{},
 		length: 1,
-		escape: s.Escape,
+		escape: s.escape(),
 		delim:  s.Delimiter,
+		syntax: s.Syntax,
+		norm:   s.KeyNormalizer,
 	}
-	if s.Flags&Tsafe > 0 {
+	if s.Flags&(Tsafe|Tatomic) > 0 {
 		tr.mu = &sync.RWMutex{}
 		tr.safe = true
 	}
-	tr.bd = &builder{
+	tr.runes = s.Flags&Trunes > 0
+	if s.Flags&Tatomic > 0 {
+		tr.atomic = true
+		tr.publish()
+	}
+	tr.bd = &printer{
 		Builder: &strings.Builder{},
 		debug:   s.Flags&Tdebug > 0,
 	}
@@ -91,303 +145,715 @@ func (tr *Tree) Add(label string, v interface{}) error {
 		defer tr.mu.Unlock()
 		tr.mu.Lock()
 	}
-	// Check label
-	inEscape := false
-	for i := range label {
-		if label[i] == tr.escape {
-			if inEscape {
-				return ErrInvalid
-			}
-			inEscape = true
-		}
-		if label[i] == tr.delim {
-			inEscape = false
-		}
+	if tr.atomic {
+		defer tr.publish()
+	}
+	_, err := tr.add(label, v, false)
+	return err
+}
+
+// Set adds a new node to the tree or, if the label
+// already exists, replaces its node's value.
+func (tr *Tree) Set(label string, v interface{}) error {
+	// No empty strings or interfaces allowed.
+	if label == "" || v == nil {
+		return nil
+	}
+	if tr.safe {
+		defer tr.mu.Unlock()
+		tr.mu.Lock()
+	}
+	if tr.atomic {
+		defer tr.publish()
+	}
+	_, err := tr.add(label, v, true)
+	return err
+}
+
+// add adds a new node to the tree and returns the previous value
+// of its label, which may only be replaced if replace is true.
+func (tr *Tree) add(label string, v interface{}, replace bool) (interface{}, error) {
+	label = tr.normalize(label)
+	if err := tr.validate(label); err != nil {
+		return nil, err
+	}
+	if tr.conflicts(label) {
+		return nil, ErrEscape
+	}
+	if tr.copying() {
+		tr.own(label)
+	}
+	if tr.weighted {
+		defer tr.reweigh(label)
 	}
 	tnode := tr.root
 	for {
-		var next *edge
-		var slice string
-		inEscape = false
-		for _, edge := range tnode.edges {
-			var found int
-			slice = edge.label
-			for i := range slice {
-				if i < len(label) && slice[i] == label[i] {
-					if label[i] == tr.escape {
-						if inEscape {
-							return ErrInvalid
-						}
-						inEscape = true
-					}
-					if label[i] == tr.delim {
-						inEscape = false
+		next := tr.childFor(tnode, label)
+		if next == nil {
+			// Different placeholders may only share a node when they
+			// can be told apart by their constraints.
+			//
+			// Example:
+			// 	(root) -> ("@id:int", v1)
+			// 	then add "@name"
+			// 	(root) -> ("@id:int", v1)
+			// 	       -> ("@name", v2)
+			if label[0] == tr.escape {
+				for e := tnode.child(tr.escape); e != nil; e = tnode.nextChild(e) {
+					if tr.ambiguous(e.label, label) {
+						return nil, ErrEscape
 					}
-					found++
-					continue
 				}
-				break
-			}
-			if inEscape {
-				return ErrEscape
-			}
-			if found > 0 {
-				label = label[found:]
-				slice = slice[found:]
-				next = edge
-				break
 			}
+			tnode.addEdge(&edge{
+				label: label,
+				node: &Node{
+					Value: v,
+					depth: tnode.depth + 1,
+				},
+			}, tr.escape)
+			tr.length++
+			tr.size += len(label)
+			return nil, nil
 		}
-		if next != nil {
-			tnode = next.node
-			// Match the whole word.
-			if len(label) == 0 {
-				// The label is exactly the same as the edge's label,
-				// so just replace its node's value.
-				//
-				// Example:
-				// 	(root) -> tnode("tomato", v1)
-				// 	becomes
-				// 	(root) -> tnode("tomato", v2)
-				if len(slice) == 0 {
-					return ErrEscape
-					// tnode.Value = v
-					// return nil
-				}
-				// The label is a prefix of the edge's label.
-				//
-				// Example:
-				// 	(root) -> tnode("tomato", v1)
-				// 	then add "tom"
-				// 	(root) -> ("tom", v2) -> ("ato", v1)
-				next.label = next.label[:len(next.label)-len(slice)]
-				c := tnode.clone()
-				tnode.edges = []*edge{
-					&edge{
-						label: slice,
-						node:  c,
-					},
+		found := tr.common(next.label, label)
+		label = label[found:]
+		slice := next.label[found:]
+		tnode = next.node
+		// Match the whole word.
+		if len(label) == 0 {
+			// The label is exactly the same as the edge's label,
+			// so just replace its node's value.
+			//
+			// Example:
+			// 	(root) -> tnode("tomato", v1)
+			// 	becomes
+			// 	(root) -> tnode("tomato", v2)
+			if len(slice) == 0 {
+				if tnode.Value != nil && !replace {
+					return nil, ErrEscape
 				}
+				old := tnode.Value
 				tnode.Value = v
-				tr.length++
-				return nil
+				return old, nil
 			}
-			// Add a new node but break its parent into prefix and
-			// the remaining slice as a new edge.
+			// The label is a prefix of the edge's label.
 			//
 			// Example:
-			// 	(root) -> ("tomato", v1)
-			// 	then add "tornado"
-			// 	(root) -> ("to", nil) -> ("mato", v1)
-			// 	                      +> ("rnado", v2)
-			if len(slice) > 0 {
-				c := tnode.clone()
-				tnode.edges = []*edge{
-					&edge{ // the suffix that is clone into a new node
-						label: slice,
-						node:  c,
-					},
-					&edge{ // the new node
-						label: label,
-						node: &Node{
-							Value: v,
-							depth: tnode.depth + 1,
-						},
-					},
-				}
-				next.label = next.label[:len(next.label)-len(slice)]
-				tnode.Value = nil
-				tr.length += 2
-				tr.size += len(label)
-				return nil
-			}
-			continue
+			// 	(root) -> tnode("tomato", v1)
+			// 	then add "tom"
+			// 	(root) -> ("tom", v2) -> ("ato", v1)
+			next.label = next.label[:found]
+			c := tr.clone(tnode)
+			tnode.setEdges(&edge{
+				label: slice,
+				node:  c,
+			})
+			tnode.Value = v
+			tnode.weight = 0
+			tr.length++
+			return nil, nil
 		}
-		// Make sure the edge with escape prefixed label is placed
-		// on the last edges.
+		// Add a new node but break its parent into prefix and
+		// the remaining slice as a new edge.
 		//
 		// Example:
-		//  (root) -> ("users", v1)
-		//         -> ("@uid", v2)
-		//  then add ("all", v3)
-		//  (root) -> ("users", v1)
-		//         -> ("all", v3)
-		//         -> ("@uid", v2)
-		var e byte
-		if l := len(tnode.edges); l > 0 {
-			e = tnode.edges[len(tnode.edges)-1].label[0]
-		}
-		if e == tr.escape {
-			// Insert new edge before the last edge.
-			tnode.edges = append(tnode.edges, &edge{})
-			copy(tnode.edges[len(tnode.edges)-1:], tnode.edges[len(tnode.edges)-2:])
-			tnode.edges[len(tnode.edges)-2] = &edge{
-				label: label,
-				node: &Node{
-					Value: v,
-					depth: tnode.depth + 1,
-				},
+		// 	(root) -> ("tomato", v1)
+		// 	then add "tornado"
+		// 	(root) -> ("to", nil) -> ("mato", v1)
+		// 	                      +> ("rnado", v2)
+		if len(slice) > 0 {
+			if slice[0] == tr.escape && label[0] == tr.escape && tr.ambiguous(slice, label) {
+				return nil, ErrEscape
 			}
-		} else {
-			tnode.edges = append(tnode.edges, &edge{
+			c := tr.clone(tnode)
+			tnode.setEdges(&edge{ // the suffix that is clone into a new node
+				label: slice,
+				node:  c,
+			})
+			tnode.addEdge(&edge{ // the new node
 				label: label,
 				node: &Node{
 					Value: v,
 					depth: tnode.depth + 1,
 				},
-			})
+			}, tr.escape)
+			next.label = next.label[:found]
+			tnode.Value = nil
+			tnode.weight = 0
+			tr.length += 2
+			tr.size += len(label)
+			return nil, nil
 		}
-		tr.length++
-		tr.size += len(label)
-		return nil
 	}
 }
 
+// conflicts reports whether a label conflicts with the labels of the tree.
+//
+// A label ending with a placeholder conflicts with the same label followed
+// by a lone delimiter, as both would match the labels ending with a delimiter,
+// and a label ending with an optional placeholder conflicts with the same
+// label without its last segment, as both would match it.
+func (tr *Tree) conflicts(label string) bool {
+	if base, ok := tr.trimOptional(label); ok {
+		n, rest, ok := tr.locate(base)
+		if !ok {
+			return false
+		}
+		if rest == "" && n.Value != nil {
+			return true
+		}
+		o, _ := tr.optional(n, rest)
+		return o != nil && o != tr.find(tr.root, label)
+	}
+	if n, rest, ok := tr.locate(label); ok {
+		if o, _ := tr.optional(n, rest); o != nil {
+			return true
+		}
+	}
+	var other string
+	if n := len(label) - 1; label[n] == tr.delim {
+		other = label[:n]
+		if !tr.endsInPlaceholder(other) {
+			return false
+		}
+	} else {
+		if !tr.endsInPlaceholder(label) {
+			return false
+		}
+		other = label + string(tr.delim)
+	}
+	n := tr.find(tr.root, other)
+	return n != nil && n.Value != nil
+}
+
+// trimOptional returns the label without its last segment,
+// if it is an optional placeholder.
+func (tr *Tree) trimOptional(label string) (string, bool) {
+	i := strings.LastIndexByte(label, tr.delim)
+	if i < 0 || !tr.isOptional(label[i+1:]) {
+		return "", false
+	}
+	return label[:i], true
+}
+
+// locate returns the node the label leads to, placeholders included, along
+// with the rest of the edge's label leading to it when the label ends in
+// the middle of that edge. It reports whether the label leads anywhere.
+func (tr *Tree) locate(label string) (*Node, string, bool) {
+	n := tr.root
+	for label != "" {
+		e := tr.childFor(n, label)
+		if e == nil {
+			return nil, "", false
+		}
+		if !strings.HasPrefix(label, e.label) {
+			if !strings.HasPrefix(e.label, label) {
+				return nil, "", false
+			}
+			return e.node, e.label[len(label):], true
+		}
+		n = e.node
+		label = label[len(e.label):]
+	}
+	return n, "", true
+}
+
+// find returns the node holding exactly the label, placeholders included.
+func (tr *Tree) find(n *Node, label string) *Node {
+	for label != "" {
+		e := tr.childFor(n, label)
+		if e == nil || !strings.HasPrefix(label, e.label) {
+			return nil
+		}
+		n = e.node
+		label = label[len(e.label):]
+	}
+	return n
+}
+
 // Del deletes a node.
 //
 // If a parent node that holds no value ends up holding only one edge
 // after a deletion of one of its edges, it gets merged with the remaining edge.
 func (tr *Tree) Del(label string) {
-	if string(label) == "" {
+	if label == "" {
 		return
 	}
 	if tr.safe {
 		defer tr.mu.Unlock()
 		tr.mu.Lock()
 	}
+	if tr.atomic {
+		defer tr.publish()
+	}
+	tr.del(label)
+}
+
+// del deletes a node and returns its value.
+func (tr *Tree) del(label string) interface{} {
+	label = tr.normalize(label)
+	if tr.copying() {
+		tr.own(label)
+	}
+	if tr.weighted {
+		defer tr.reweigh(label)
+	}
 	tnode := tr.root
-	var edgex int
-	var parent *edge
-	for tnode != nil && label != "" {
-		var next *edge
-		// Look for exact matches.
-		for i, e := range tnode.edges {
-			if strings.HasPrefix(label, e.label) {
-				next = e
-				edgex = i
-				break
-			}
+	var (
+		pnode *Node // tnode's parent
+		pedge *edge // the edge that leads to pnode
+		tedge *edge // the edge that leads to tnode
+	)
+	// Look for exact matches.
+	for label != "" {
+		e := tr.childFor(tnode, label)
+		if e == nil || !strings.HasPrefix(label, e.label) {
+			return nil
 		}
-		if next != nil {
-			tnode = next.node
-			label = label[len(next.label):]
-			// While not the exact match, set the tnode's parent.
-			if label != "" {
-				parent = next
-			}
-			continue
+		pnode, pedge, tedge = tnode, tedge, e
+		tnode = e.node
+		label = label[len(e.label):]
+	}
+	if tnode.Value == nil {
+		return nil
+	}
+	v := tnode.Value
+	tnode.Value = nil
+	tnode.weight = 0
+	switch len(tnode.edges) {
+	case 0:
+		// Remove tnode from the parent.
+		pnode.delEdge(tedge)
+		tr.length--
+		tr.size -= len(tedge.label)
+		// When only one edge remained in pnode and its value is nil, they can be merged.
+		if len(pnode.edges) == 1 && pnode.Value == nil && pnode != tr.root {
+			tr.merge(pedge)
 		}
-		// No matches.
-		parent = nil
-		tnode = nil
+	case 1:
+		tr.merge(tedge)
 	}
-	if tnode != nil {
-		pnode := tr.root // in case label matched in the first try
-		if parent != nil {
-			pnode = parent.node
+	return v
+}
+
+// merge merges the edge's node with its only edge.
+//
+// Example:
+//
+//	(root) -> ("to", nil) -> ("mato", v1)
+//	becomes
+//	(root) -> ("tomato", v1)
+func (tr *Tree) merge(e *edge) {
+	c := e.node.edges[0]
+	e.label += c.label
+	switch {
+	case tr.atomic:
+		// The node's children are shared with published versions.
+		n := *c.node
+		n.depth--
+		e.node = &n
+	case tr.snap != nil:
+		// The node is shared with the tree as it was before the batch.
+		n := *c.node
+		n.decrDepth()
+		tr.snap.shifted(n.edges, -1)
+		e.node = &n
+	default:
+		e.node = c.node
+		e.node.decrDepth()
+	}
+	tr.length--
+}
+
+// clone copies a node one level deeper in the tree.
+//
+// When the tree is read without locks, its children are shared with
+// published versions, so their depths are left as they are and
+// readers work them out instead.
+func (tr *Tree) clone(n *Node) *Node {
+	if tr.atomic {
+		c := *n
+		c.depth++
+		return &c
+	}
+	c := n.clone()
+	if tr.snap != nil {
+		tr.snap.shifted(c.edges, 1)
+	}
+	return c
+}
+
+// copying reports whether writers copy the nodes they modify, which they
+// do when the tree is read without locks and while a batch is running.
+func (tr *Tree) copying() bool {
+	return tr.atomic || tr.snap != nil
+}
+
+// own copies the root and all nodes and edges in the label's path, so that
+// writers never modify nodes which are reachable from a published version
+// or from the tree as it was before the running batch.
+func (tr *Tree) own(label string) {
+	tr.root = tr.root.copy()
+	tnode := tr.root
+	for label != "" {
+		e := tr.childFor(tnode, label)
+		if e == nil {
+			return
 		}
-		// Merge tnode's edges with the parent's.
-		pnode.edges = append(pnode.edges, tnode.edges...)
-		// Remove tnode from the parent, leaving only its edges behind.
-		pnode.edges = append(pnode.edges[:edgex], pnode.edges[edgex+1:]...)
-		// When only one edge remained in pnode and its value is nil, they can be merged.
-		if len(pnode.edges) == 1 && pnode.Value == nil && parent != nil {
-			e := pnode.edges[0]
-			parent.label += e.label
-			pnode.Value = e.node.Value
-			pnode.edges = e.node.edges
-			tr.length--
+		c := &edge{
+			label: e.label,
+			node:  e.node.copy(),
 		}
-		tr.length--
+		tnode.replaceEdge(e, c)
+		if !strings.HasPrefix(label, e.label) {
+			return
+		}
+		label = label[len(e.label):]
+		tnode = c.node
+	}
+}
+
+// publish publishes the current state of the tree for lock-free readers.
+func (tr *Tree) publish() {
+	tr.cur.Store(&version{
+		root:   tr.root,
+		length: tr.length,
+		size:   tr.size,
+	})
+}
+
+// rlock locks the tree for reading and returns its current state.
+//
+// Trees with lock-free reads are never locked, and
+// their latest published version is returned instead.
+func (tr *Tree) rlock() version {
+	if tr.atomic {
+		return *tr.cur.Load()
+	}
+	if tr.safe {
+		tr.mu.RLock()
+	}
+	return version{
+		root:   tr.root,
+		length: tr.length,
+		size:   tr.size,
+	}
+}
+
+// runlock undoes a single call to rlock.
+func (tr *Tree) runlock() {
+	if tr.safe && !tr.atomic {
+		tr.mu.RUnlock()
 	}
 }
 
 // Get retrieves a node.
+//
+// The label is matched literally, so its escape symbols match
+// the doubled ones of the tree's labels, such as "/@@me".
 func (tr *Tree) Get(label string) (*Node, map[string]string) {
 	if label == "" {
 		return nil, nil
 	}
-	if tr.safe {
-		defer tr.mu.RUnlock()
-		tr.mu.RLock()
+	defer tr.runlock()
+	return tr.get(tr.rlock().root, label)
+}
+
+func (tr *Tree) get(root *Node, label string) (*Node, map[string]string) {
+	s := tr.newSearch(label)
+	n := tr.lookup(root, s.key, &s)
+	if n != nil {
+		n = n.at(s.depth)
 	}
-	tnode := tr.root
-	var params map[string]string
-	for tnode != nil && label != "" {
+	return n, paramMap(n, s.params)
+}
+
+// getRoot retrieves a node through the root's edges starting with c
+// and reports whether one was found.
+func (tr *Tree) getRoot(root *Node, label string, c byte) (*Node, map[string]string, bool) {
+	s := tr.newSearch(label)
+	n := tr.lookupEdges(root, c, s.key, &s)
+	if n != nil {
+		n = n.at(s.depth)
+	}
+	return n, paramMap(n, s.params), n != nil
+}
+
+// search is the state of a lookup.
+type search struct {
+	key    string // the label looked up, normalized
+	orig   string // the label looked up
+	offs   []int  // offsets of key's bytes in orig, unless they are the same
+	params []param
+	depth  int    // depth of the node being visited or, once found, of the node found
+	trace  *Trace // the steps taken, only when explaining a lookup
+}
+
+// param is a matched placeholder.
+type param struct {
+	key   string
+	value string
+}
+
+// paramMap returns the named placeholders matched on the way to n.
+func paramMap(n *Node, params []param) map[string]string {
+	if n == nil {
+		return nil
+	}
+	var m map[string]string
+	for _, p := range params {
+		if p.key == "" {
+			continue
+		}
+		if m == nil {
+			m = make(map[string]string, len(params))
+		}
+		m[p.key] = p.value
+	}
+	return m
+}
+
+// lookup returns the node below n, holding a value, that matches the label,
+// and appends the placeholders matched on the way to the search's params.
+//
+// Static edges are tried first, then the ones holding a placeholder. Whenever
+// the label can't be matched below an edge, the next one is tried.
+func (tr *Tree) lookup(n *Node, label string, s *search) *Node {
+	if s.trace != nil {
+		s.trace.add(Step{Kind: StepVisit, Depth: s.depth, Label: label})
+	}
+	if label == "" {
+		if n.Value != nil {
+			return n
+		}
+		o, depth := tr.optional(n, "")
+		if o != nil {
+			s.depth += depth
+		} else if s.trace != nil {
+			s.trace.add(Step{Kind: StepReject, Depth: s.depth, Reason: "label exhausted at a node holding no value"})
+		}
+		return o
+	}
+	if label[0] != tr.escape {
+		if found := tr.lookupEdges(n, label[0], label, s); found != nil {
+			return found
+		}
+	}
+	return tr.lookupEdges(n, tr.escape, label, s)
+}
+
+// lookupEdges is like lookup, but only tries edges starting with c.
+//
+// Literal escape symbols are tried first, then constrained placeholders,
+// unconstrained ones and "{path...}" placeholders last, in the order
+// given by tries.
+func (tr *Tree) lookupEdges(n *Node, c byte, label string, s *search) *Node {
+	e := n.child(c)
+	if e == nil {
+		if s.trace != nil {
+			s.trace.add(Step{Kind: StepReject, Depth: s.depth, Label: label, Reason: fmt.Sprintf("no edge starting with %q", c)})
+		}
+		return nil
+	}
+	if c != tr.escape {
+		// Static edges only share their first byte when they start
+		// with different runes, so that at most one of them matches.
+		for ; e != nil; e = n.nextChild(e) {
+			if found := tr.follow(e, 0, label, s); found != nil {
+				return found
+			}
+		}
+		return nil
+	}
+	var prev *edge
+	for {
+		// Placeholders are few, so the next one is looked for each time.
 		var next *edge
-	Walk:
-		for _, edge := range tnode.edges {
-			slice := edge.label
-			for {
-				phIndex := len(slice)
-				// Check if there are any placeholders.
-				// If there are none, then use the whole word for comparison.
-				if i := strings.IndexByte(slice, tr.escape); i >= 0 {
-					phIndex = i
-				}
-				prefix := slice[:phIndex]
-				// If "slice" (until placeholder) is not prefix of
-				// "label", then keep walking.
-				if !strings.HasPrefix(label, prefix) {
-					continue Walk
-				}
-				label = label[len(prefix):]
-				// If "slice" is the whole label,
-				// then the match is complete and the algorithm
-				// is ready to go to the next edge.
-				if len(prefix) == len(slice) {
-					next = edge
-					break Walk
-				}
-				// Check whether there is a delimiter.
-				// If there isn'tr, then use the whole world as parameter.
-				var delimIndex int
-				var whole bool
-				slice = slice[phIndex:]
-				if delimIndex = strings.IndexByte(slice[1:], tr.delim) + 1; delimIndex <= 0 {
-					delimIndex = len(slice)
-					whole = true
-				}
-				key := slice[1:delimIndex] // remove the placeholder from the map key
-				slice = slice[delimIndex:]
-				if delimIndex = strings.IndexByte(label[1:], tr.delim) + 1; delimIndex <= 0 || whole {
-					delimIndex = len(label)
-				}
-				if len(key) > 0 {
-					if params == nil {
-						params = make(map[string]string)
-					}
-					params[key] = label[:delimIndex]
-				}
-				label = label[delimIndex:]
-				if slice == "" && label == "" {
-					next = edge
-					break Walk
-				}
+		for e := n.child(c); e != nil; e = n.nextChild(e) {
+			if (prev == nil || tr.tries(prev.label, e.label)) && (next == nil || tr.tries(e.label, next.label)) {
+				next = e
 			}
 		}
-		if next != nil {
-			tnode = next.node
-			continue
+		if next == nil {
+			return nil
+		}
+		if found := tr.follow(next, 0, label, s); found != nil {
+			return found
 		}
-		tnode = nil
+		prev = next
 	}
-	return tnode, params
+}
+
+// rank returns the class in which lookupEdges tries an edge whose label
+// starts with the escape symbol, along with its placeholder's suffix.
+//
+// Placeholders with a suffix, such as "@name.json", are tried before
+// those without one in each class.
+func (tr *Tree) rank(label string) (int, string) {
+	if tr.literal(label, 0) {
+		return 0, ""
+	}
+	p, _ := tr.placeholder(label, 0)
+	rank := 0
+	switch {
+	case p.rest:
+		rank = 5
+	case p.constraint == "":
+		rank = 3
+	default:
+		rank = 1
+	}
+	if p.suffix == "" {
+		rank++
+	}
+	return rank, p.suffix
+}
+
+// tries reports whether lookupEdges tries the edge labeled a before the one
+// labeled b, sibling edges starting with the escape symbol: by their class,
+// then longest suffixes first and by suffix, so that the placeholders'
+// names never decide, unless only their constraints differ.
+func (tr *Tree) tries(a, b string) bool {
+	ra, sa := tr.rank(a)
+	rb, sb := tr.rank(b)
+	switch {
+	case ra != rb:
+		return ra < rb
+	case len(sa) != len(sb):
+		return len(sa) > len(sb)
+	case sa != sb:
+		return sa < sb
+	}
+	return a < b
+}
+
+// follow matches the label against the edge's label from index i
+// and then looks the rest of the label up below the edge's node.
+//
+// Placeholders match until the label's next delimiter, less their suffix,
+// or until any of the following ones if their constraint holds a delimiter.
+// A placeholder that ends a label may also match the whole remainder of the
+// label, and an optional one may match nothing, along with its delimiter.
+func (tr *Tree) follow(e *edge, i int, label string, s *search) *Node {
+	slice := e.label[i:]
+	if s.trace != nil && i == 0 {
+		s.trace.add(Step{Kind: StepEdge, Depth: s.depth, Edge: slice, Label: label})
+	}
+	j := strings.IndexByte(slice, tr.escape)
+	if j < 0 {
+		j = len(slice)
+	}
+	k := j // length of the label's static part
+	if !strings.HasPrefix(label, slice[:j]) {
+		if strings.HasPrefix(slice, label) {
+			return tr.omit(e, slice[len(label):], s)
+		}
+		if s.trace != nil {
+			s.trace.reject(s.depth, slice, fmt.Sprintf("prefix mismatch, expected %q", slice[:j]))
+		}
+		return nil
+	}
+	// Literal escape symbols match a single one.
+	for tr.literal(slice, j) {
+		if k == len(label) || label[k] != tr.escape {
+			if s.trace != nil {
+				s.trace.reject(s.depth, slice, fmt.Sprintf("prefix mismatch, expected %q", tr.escape))
+			}
+			return nil
+		}
+		j, k = j+2, k+1
+		n := strings.IndexByte(slice[j:], tr.escape)
+		if n < 0 {
+			n = len(slice) - j
+		}
+		if !strings.HasPrefix(label[k:], slice[j:j+n]) {
+			if strings.HasPrefix(slice[j:], label[k:]) {
+				return tr.omit(e, slice[j+len(label)-k:], s)
+			}
+			if s.trace != nil {
+				s.trace.reject(s.depth, slice, fmt.Sprintf("prefix mismatch, expected %q", slice[j:j+n]))
+			}
+			return nil
+		}
+		j, k = j+n, k+n
+	}
+	if j == len(slice) {
+		s.depth++
+		if found := tr.lookup(e.node, label[k:], s); found != nil {
+			return found
+		}
+		s.depth--
+		return nil
+	}
+	label = label[k:]
+	p, _ := tr.placeholder(slice, j)
+	end := strings.IndexByte(label, tr.delim)
+	if end < 0 {
+		end = len(label)
+	}
+	mark := len(s.params)
+	for {
+		if v, ok := s.capture(p, label, end); ok {
+			s.params = append(s.params, param{key: p.name, value: v})
+			if s.trace != nil {
+				s.trace.add(Step{Kind: StepParam, Depth: s.depth, Param: p.name, Value: v})
+			}
+			if found := tr.follow(e, i+p.end, label[end:], s); found != nil {
+				return found
+			}
+			s.params = s.params[:mark]
+		} else if s.trace != nil {
+			s.trace.reject(s.depth, slice[j:], tr.mismatch(p, label[:end]))
+		}
+		if !p.spans || end == len(label) {
+			break
+		}
+		if n := strings.IndexByte(label[end+1:], tr.delim); n >= 0 {
+			end += 1 + n
+		} else {
+			end = len(label)
+		}
+	}
+	if i+p.end == len(e.label) && e.node.Value != nil && end < len(label) && tr.multi(p) {
+		if v, ok := s.capture(p, label, len(label)); ok {
+			s.params = append(s.params, param{key: p.name, value: v})
+			if s.trace != nil {
+				s.trace.add(Step{Kind: StepParam, Depth: s.depth, Param: p.name, Value: v})
+			}
+			s.depth++
+			return e.node
+		}
+	}
+	if s.trace != nil && end < len(label) {
+		s.trace.reject(s.depth, slice[j:], fmt.Sprintf("nothing matched below, and the placeholder doesn't match across the delimiter %q", tr.delim))
+	}
+	return nil
+}
+
+// omit returns what tr.optional does for the rest of an edge's label
+// when the label ends in its middle, explaining why when nothing.
+func (tr *Tree) omit(e *edge, rest string, s *search) *Node {
+	o, depth := tr.optional(e.node, rest)
+	if o != nil {
+		s.depth += 1 + depth
+	} else if s.trace != nil {
+		s.trace.reject(s.depth, rest, "label exhausted in the middle of the edge")
+	}
+	return o
 }
 
 // Len returns the total numbers of nodes,
 // including the tree's root.
 func (tr *Tree) Len() int {
-	if tr.safe {
-		defer tr.mu.RUnlock()
-		tr.mu.RLock()
-	}
-	return tr.length
+	defer tr.runlock()
+	return tr.rlock().length
 }
 
 // Size returns the total byte size stored in the tree.
 func (tr *Tree) Size() int {
-	return tr.size
+	defer tr.runlock()
+	return tr.rlock().size
 }
 
 // Sort sorts the tree nodes and its children recursively
@@ -397,27 +863,63 @@ func (tr *Tree) Sort(st SortingTechnique) {
 		defer tr.mu.Unlock()
 		tr.mu.Lock()
 	}
+	if tr.atomic {
+		defer tr.publish()
+		tr.root = tr.root.copyTree(0)
+	}
 	tr.root.sort(st)
 }
 
 // String returns a string representation of the tree structure.
 func (tr *Tree) String() string {
-	if tr.safe {
-		defer tr.mu.RUnlock()
-		tr.mu.RLock()
+	defer tr.runlock()
+	v := tr.rlock()
+	return tr.bd.print(v.root, v.length)
+}
+
+// Walk calls fn for every node holding a value, in ascending
+// order of their labels, until fn returns false.
+//
+// The tree must not be modified by fn.
+func (tr *Tree) Walk(fn func(label string, n *Node) bool) {
+	defer tr.runlock()
+	tr.rlock().root.walk(nil, 0, fn)
+}
+
+// WalkPrefix calls fn for every node holding a value whose label starts
+// with prefix, in ascending order of their labels, until fn returns false.
+//
+// Placeholders are not expanded, labels are compared as they were added.
+// The tree must not be modified by fn.
+func (tr *Tree) WalkPrefix(prefix string, fn func(label string, n *Node) bool) {
+	prefix = tr.normalize(prefix)
+	defer tr.runlock()
+	if tnode, label, depth := tr.seek(tr.rlock().root, prefix); tnode != nil {
+		tnode.walk(label, depth, fn)
 	}
-	bd := tr.bd
-	bd.Reset()
-	bd.WriteString(bd.colors[colorBold].Wrap("\n."))
-	if tr.bd.debug {
-		mag := bd.colors[colorMagenta]
-		bd.WriteString(mag.Wrapf(" (%d node", tr.length))
-		if tr.length != 1 {
-			bd.WriteString(mag.Wrap("s")) // avoid writing "1 nodes"
+}
+
+// seek returns the highest node below n, the root, whose label starts with
+// prefix, along with its label and depth, or nil if no label starts with prefix.
+func (tr *Tree) seek(n *Node, prefix string) (*Node, []byte, int) {
+	label := make([]byte, 0, len(prefix))
+	depth := 0
+	for len(label) < len(prefix) {
+		rest := prefix[len(label):]
+		e := tr.childFor(n, rest)
+		if e == nil {
+			return nil, nil, 0
+		}
+		if !strings.HasPrefix(rest, e.label) && !strings.HasPrefix(e.label, rest) {
+			return nil, nil, 0
 		}
-		bd.WriteString(mag.Wrap(")"))
+		label = append(label, e.label...)
+		n = e.node
+		depth++
+	}
+	if tr.runes && len(label) > len(prefix) && !utf8.RuneStart(label[len(prefix)]) {
+		// The prefix ends in the middle of a rune.
+		return nil, nil, 0
 	}
-	tr.bd.WriteByte('\n')
-	tr.root.writeTo(tr.bd)
-	return tr.bd.String()
+	return n, label, depth
 }
@@ -261,3 +261,74 @@ func TestTree(t *testing.T) {
 		})
 	}
 }
+
+func TestWideNode(t *testing.T) {
+	tr := New()
+	var labels []string
+	for c := 0; c < 256; c++ {
+		if c == '@' {
+			continue
+		}
+		labels = append(labels, string([]byte{byte(c), 'x'}), string([]byte{byte(c), 'x', 'y'}))
+	}
+	assert.Nil(t, tr.Add("@id", "param"))
+	for i, l := range labels {
+		assert.Nil(t, tr.Add(l, i))
+	}
+	for i, l := range labels {
+		n, p := tr.Get(l)
+		if assert.NotNil(t, n, l) {
+			assert.Equal(t, i, n.Value)
+			assert.Equal(t, 0, len(p))
+		}
+	}
+	n, p := tr.Get("@@")
+	assert.Equal(t, "param", n.Value)
+	assert.Equal(t, "@@", p["id"])
+	tr.Del("@id")
+
+	for i, l := range labels {
+		if i%2 == 0 {
+			tr.Del(l)
+		}
+	}
+	for i, l := range labels {
+		n, _ := tr.Get(l)
+		if i%2 == 0 {
+			assert.Nil(t, n, l)
+			continue
+		}
+		if assert.NotNil(t, n, l) {
+			assert.Equal(t, i, n.Value)
+			assert.Equal(t, 1, n.Depth())
+		}
+	}
+	// Only the root and the remaining edges are left.
+	assert.Equal(t, 1+len(labels)/2, tr.Len())
+	assert.Equal(t, 3*len(labels)/2, tr.Size())
+}
+
+func TestDel(t *testing.T) {
+	tr := New()
+	assert.Nil(t, tr.Add("tomato", 1))
+	assert.Nil(t, tr.Add("tornado", 2))
+	assert.Nil(t, tr.Add("tornados", 3))
+	assert.Equal(t, 5, tr.Len())
+
+	// Deleting a node without a value does nothing.
+	tr.Del("to")
+	assert.Equal(t, 5, tr.Len())
+
+	tr.Del("tornado")
+	n, _ := tr.Get("tornados")
+	assert.Equal(t, 3, n.Value)
+	assert.Equal(t, 2, n.Depth())
+	assert.Equal(t, 4, tr.Len())
+
+	tr.Del("tomato")
+	n, _ = tr.Get("tornados")
+	assert.Equal(t, 3, n.Value)
+	assert.Equal(t, 1, n.Depth())
+	assert.Equal(t, 2, tr.Len())
+	assert.Equal(t, len("tornados"), tr.Size())
+}